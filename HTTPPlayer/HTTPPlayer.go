@@ -3,14 +3,17 @@ package HTTPPlayer
 
 import (
 	"SaaS/board"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"io/ioutil"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -42,6 +45,16 @@ type HTTPPlayer struct {
 		files map[string]string
 	}
 	sosach *board.Board
+	client *board.Client
+
+	// Control of HTTP server and background goroutines
+	lifecycle struct {
+		sync.Mutex
+		cancel context.CancelFunc
+		server *http.Server
+		served chan error
+		wait   sync.WaitGroup
+	}
 }
 
 type sessionType struct {
@@ -50,8 +63,28 @@ type sessionType struct {
 	effectivePosition int
 }
 
-// Function to create Player and fill all necessary fields
+// Function to create Player for 2ch and fill all necessary fields
 func NewHTTPPlayer(SaveDirectory, Cookie, BrowserUserAgent, BoardAddress, DownloadURL, JSONUrl, Port string) (*HTTPPlayer, error) {
+	client := board.NewClient(BrowserUserAgent)
+	if Cookie != "" {
+		err := client.SetCookie(DownloadURL, "__cfduid", Cookie)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	player, err := NewHTTPPlayerWithSource(SaveDirectory, Port, client, board.NewMakaba(client, JSONUrl, DownloadURL, BoardAddress))
+	if err != nil {
+		return player, err
+	}
+	player.Config.Cookie = Cookie
+	player.Config.DownloadURL = DownloadURL
+	player.Config.BoardAddress = BoardAddress
+	return player, nil
+}
+
+// Function to create Player which streams files from any source. Client is used to download files from origin.
+func NewHTTPPlayerWithSource(SaveDirectory, Port string, client *board.Client, source board.Source) (*HTTPPlayer, error) {
 	player := new(HTTPPlayer)
 
 	// init sessions map
@@ -67,24 +100,26 @@ func NewHTTPPlayer(SaveDirectory, Cookie, BrowserUserAgent, BoardAddress, Downlo
 
 	// Initial configutation
 	player.Config.SaveDirectory = SaveDirectory
-	player.Config.Cookie = Cookie
-	player.Config.BrowserUserAgent = BrowserUserAgent
-	player.Config.DownloadURL = DownloadURL
-	player.Config.BoardAddress = BoardAddress
+	player.Config.BrowserUserAgent = client.BrowserUserAgent
 	player.Config.Port = Port
 	player.Config.Tempdir = tempdir
+	player.client = client
 
 	// temporary queue for debug
-	//player.sosach.Queue = []board.FileInfo{{"14450448066140.webm", "src/104033532/14450448066140.webm"}, {"14450448067471.webm", "src/104033532/14450448067471.webm"}, {"14450448629300.webm", "src/104033532/14450448629300.webm"}}
+	//player.sosach.Queue.Push(board.FileInfo{Name: "14450448066140.webm", Path: "src/104033532/14450448066140.webm"})
 
 	// Init ImageBoard watcher
-	sosach, err := board.NewBoard(JSONUrl, DownloadURL, BrowserUserAgent, Cookie)
+	sosach, err := board.NewBoardWithSource(source)
 	if err != nil {
 		log.Fatalln("Error inititating new board instance: ", err)
 	}
 	player.sosach = sosach
 
-	player.sosach.AutoWatcher()
+	// Restore queue from previous run
+	err = player.sosach.LoadState(player.Config.SaveDirectory + string(os.PathSeparator) + "board.json")
+	if err != nil {
+		log.Println("Error on loading board state: ", err)
+	}
 
 	// Check if cache directory exist and create it not. If indicated path is file istead of directory, show alert and stop
 	dir, err := os.Stat(player.Config.SaveDirectory + string(os.PathSeparator) + "src")
@@ -104,11 +139,19 @@ func NewHTTPPlayer(SaveDirectory, Cookie, BrowserUserAgent, BoardAddress, Downlo
 		}
 	}
 
-	go player.startSessionsCleaner()
-
 	return player, nil
 }
 
+// Function returns board instance of player, e.g. to configure it.
+func (p *HTTPPlayer) Board() *board.Board {
+	return p.sosach
+}
+
+// Function returns client of player, e.g. to set fresh credentials.
+func (p *HTTPPlayer) Client() *board.Client {
+	return p.client
+}
+
 // Function handlig new seession creation: lock, random, etc
 func (p *HTTPPlayer) newSession(resp *http.ResponseWriter) (string, error) {
 	var sessionID string
@@ -118,10 +161,12 @@ func (p *HTTPPlayer) newSession(resp *http.ResponseWriter) (string, error) {
 		p.sessionsControl.Lock()
 		defer p.sessionsControl.Unlock()
 		if _, ok := p.sessionsControl.sessions[sessionID]; !ok {
-			position := len(p.sosach.Queue) - 10
-			if position < 0 {
-				position = 0
+			first, end := p.sosach.Queue.Bounds()
+			position := end - 10
+			if position < first {
+				position = first
 			}
+			position = p.livePosition(position, 1)
 			p.sessionsControl.sessions[sessionID] = sessionType{position, time.Now(), position}
 			break
 		}
@@ -149,7 +194,7 @@ func (p *HTTPPlayer) getRequestSession(resp *http.ResponseWriter, req *http.Requ
 	cookie, err := req.Cookie(session_cookie)
 	if err != nil {
 		log.Println("Serving new user without cookie. Generating new one.")
-		sessionID, err := p.newSession(resp)
+		sessionID, err = p.newSession(resp)
 		if err != nil {
 			return sessionID, err
 		}
@@ -158,7 +203,7 @@ func (p *HTTPPlayer) getRequestSession(resp *http.ResponseWriter, req *http.Requ
 		sessionID = cookie.Value
 		_, err := p.getSession(sessionID)
 		if err != nil {
-			sessionID, err := p.newSession(resp)
+			sessionID, err = p.newSession(resp)
 			if err != nil {
 				return sessionID, err
 			}
@@ -167,27 +212,42 @@ func (p *HTTPPlayer) getRequestSession(resp *http.ResponseWriter, req *http.Requ
 	return sessionID, nil
 }
 
-// Function to re-read cache directory and rebuild cachedFiles map
+// Function to re-read cache directory and rebuild cachedFiles map. Files are mapped by path relative to
+// src directory, the same as cacheKey.
 func (p *HTTPPlayer) refrestFileCache() {
-	cacheDir, err := ioutil.ReadDir(p.Config.SaveDirectory)
+	files := make(map[string]string)
+	srcDir := p.Config.SaveDirectory + string(os.PathSeparator) + "src"
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == srcDir {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		key, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		files[key] = path
+		return nil
+	})
 	if err != nil {
 		log.Println("Error on read directory for save files: ", err)
 	}
 	p.cachedFiles.Lock()
 	defer p.cachedFiles.Unlock()
-	p.cachedFiles.files = make(map[string]string)
-	for _, file := range cacheDir {
-		p.cachedFiles.files[file.Name()] = p.Config.SaveDirectory + string(os.PathSeparator) + file.Name()
-	}
-
+	p.cachedFiles.files = files
 }
 
 // Function to check if target file already in cache and return path, otherwise return empty string
-func (p *HTTPPlayer) getFileFromCache(file string) string {
+func (p *HTTPPlayer) getFileFromCache(file board.FileInfo) string {
 	p.cachedFiles.Lock()
 	defer p.cachedFiles.Unlock()
 
-	if path, ok := p.cachedFiles.files[file]; ok {
+	if path, ok := p.cachedFiles.files[p.cacheKey(file)]; ok {
 		return path
 	} else {
 		return ""
@@ -195,28 +255,73 @@ func (p *HTTPPlayer) getFileFromCache(file string) string {
 }
 
 // Function to add file to cache map
-func (p *HTTPPlayer) addFileToCache(name, path string) {
+func (p *HTTPPlayer) addFileToCache(file board.FileInfo, path string) {
 	p.cachedFiles.Lock()
 	defer p.cachedFiles.Unlock()
 	if p.cachedFiles.files == nil {
 		p.cachedFiles.files = make(map[string]string)
 	}
-	p.cachedFiles.files[name] = path
+	p.cachedFiles.files[p.cacheKey(file)] = path
+}
+
+// Function returns key of file in cache map: path of file relative to src directory. Files with the same
+// name from different boards or threads have different keys.
+func (p *HTTPPlayer) cacheKey(file board.FileInfo) string {
+	key := file.Thread + string(os.PathSeparator) + file.Name
+	if file.Board != "" {
+		key = file.Board + string(os.PathSeparator) + key
+	}
+	return key
+}
+
+// Function returns directory to save file in cache. Files of different boards are saved separately.
+func (p *HTTPPlayer) cacheDirectory(file board.FileInfo) string {
+	dir := p.Config.SaveDirectory + string(os.PathSeparator) + "src"
+	if file.Board != "" {
+		dir += string(os.PathSeparator) + file.Board
+	}
+	return dir + string(os.PathSeparator) + file.Thread
+}
+
+// Function returns position of the nearest file in queue which was not removed, looking in direction of
+// move first. Position is returned as is if there are no files.
+func (p *HTTPPlayer) livePosition(position, move int) int {
+	step := 1
+	if move < 0 {
+		step = -1
+	}
+	if live, ok := p.sosach.Queue.Seek(position, step); ok {
+		return live
+	}
+	if live, ok := p.sosach.Queue.Seek(position, -step); ok {
+		return live
+	}
+	return position
 }
 
-// Function to handle move position on queue
+// Function to handle move position on queue. Positions are absolute, so if files under session
+// position were evicted from queue, session is moved to the oldest file. Removed files are skipped.
 func (p *HTTPPlayer) sessionMovePos(sessionID string, move int) int {
-	length := len(p.sosach.Queue)
+	first, end := p.sosach.Queue.Bounds()
 	p.sessionsControl.Lock()
 	defer p.sessionsControl.Unlock()
-	if p.sessionsControl.sessions[sessionID].position+move < 0 || p.sessionsControl.sessions[sessionID].position+move > length-1 {
+	if p.sessionsControl.sessions[sessionID].position < first {
 		session := p.sessionsControl.sessions[sessionID]
-		session.effectivePosition = rand.Intn(length)
+		session.position = first
+		p.sessionsControl.sessions[sessionID] = session
+	}
+	if p.sessionsControl.sessions[sessionID].position+move < first || p.sessionsControl.sessions[sessionID].position+move > end-1 {
+		session := p.sessionsControl.sessions[sessionID]
+		session.effectivePosition = first
+		if end > first {
+			session.effectivePosition += rand.Intn(end - first)
+		}
+		session.effectivePosition = p.livePosition(session.effectivePosition, 1)
 		p.sessionsControl.sessions[sessionID] = session
 		return p.sessionsControl.sessions[sessionID].effectivePosition
 	} else {
 		session := p.sessionsControl.sessions[sessionID]
-		session.position += move
+		session.position = p.livePosition(session.position+move, move)
 		session.effectivePosition = session.position
 		p.sessionsControl.sessions[sessionID] = session
 		return p.sessionsControl.sessions[sessionID].position
@@ -229,10 +334,76 @@ func (p *HTTPPlayer) getEffectivePosition(sessionID string) int {
 	return p.sessionsControl.sessions[sessionID].effectivePosition
 }
 
+// Type represents /play/info response: file with links to origin
+type webmInfo struct {
+	board.FileInfo
+	URL     string `json:"url"`
+	PostURL string `json:"postUrl"`
+	Reposts int    `json:"reposts"`
+
+	ThumbnailURL string `json:"thumbnailUrl"`
+}
+
+// Type to represent board status in /status response
+type statusInfo struct {
+	Blocked       bool      `json:"blocked"`
+	BlockedSince  time.Time `json:"blockedSince"`
+	Failures      int       `json:"failures"`
+	PollFailures  int       `json:"pollFailures"`
+	LastError     string    `json:"lastError"`
+	LastErrorTime time.Time `json:"lastErrorTime"`
+	LastSuccess   time.Time `json:"lastSuccess"`
+	QueueLength   int       `json:"queueLength"`
+
+	Requests board.LimiterStats `json:"requests"`
+}
+
+// Function responds to /status requests with board health for operators
+func (p *HTTPPlayer) serveStatus(resp http.ResponseWriter, req *http.Request) {
+	status := p.sosach.Status()
+	info := statusInfo{
+		Blocked:       status.Blocked,
+		BlockedSince:  status.BlockedSince,
+		Failures:      status.Failures,
+		PollFailures:  status.PollFailures,
+		LastErrorTime: status.LastErrorTime,
+		LastSuccess:   status.LastSuccess,
+		QueueLength:   p.sosach.Queue.Len(),
+		Requests:      p.client.Stats(),
+	}
+	if status.LastError != nil {
+		info.LastError = status.LastError.Error()
+	}
+	if since := p.client.Blocked(); !since.IsZero() && !info.Blocked {
+		info.Blocked = true
+		info.BlockedSince = since
+	}
+
+	content, err := json.Marshal(info)
+	if err != nil {
+		log.Println("Error while marshaling status: ", err)
+		return
+	}
+	resp.Header().Add("Content-Type", "application/json")
+	resp.Write(content)
+}
+
 //Function responds to /play/info requests
 func (p *HTTPPlayer) getWebmInfo(resp http.ResponseWriter, req *http.Request, position int) {
+	file, ok := p.sosach.Queue.Get(position)
+	if !ok {
+		http.Error(resp, "Queue is empty, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	info := webmInfo{file, p.sosach.FileURL(file), p.sosach.PostURL(file), p.sosach.Reposts(file.MD5), ""}
+	if file.Thumbnail != "" {
+		thumbnail := file
+		thumbnail.Path = file.Thumbnail
+		info.ThumbnailURL = p.sosach.FileURL(thumbnail)
+	}
 
-	fileInfo, err := json.Marshal(p.sosach.Queue[position])
+	fileInfo, err := json.Marshal(info)
 	if err != nil {
 		log.Println("Error while marshaling file info: ", err)
 		return
@@ -242,10 +413,15 @@ func (p *HTTPPlayer) getWebmInfo(resp http.ResponseWriter, req *http.Request, po
 	resp.Write(fileInfo)
 }
 
-// Function to initiate old sessions cleaner
-func (p *HTTPPlayer) startSessionsCleaner() {
+// Function to initiate old sessions cleaner, works until context is canceled
+func (p *HTTPPlayer) startSessionsCleaner(ctx context.Context) {
+	defer p.lifecycle.wait.Done()
 	for {
-		time.Sleep(1 * time.Hour)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(1 * time.Hour):
+		}
 		log.Println("Started old sessions cleaner")
 		p.sessionsControl.Lock()
 		for key, session := range p.sessionsControl.sessions {
@@ -268,11 +444,23 @@ func (p *HTTPPlayer) servePlay(resp http.ResponseWriter, req *http.Request) {
 
 	position := p.getEffectivePosition(sessionID)
 
-	filePath := p.getFileFromCache(p.sosach.Queue[position].Name)
+	queueFile, ok := p.sosach.Queue.Get(position)
+	if !ok {
+		http.Error(resp, "No such file in queue", http.StatusNotFound)
+		return
+	}
+
+	filePath := p.getFileFromCache(queueFile)
 
 	resp.Header().Add("Accept-Ranges", "bytes")
 	resp.Header().Add("Cache-Control", "public, max-age=16070400")
 
+	mediaType := queueFile.Type
+	if mediaType == "" {
+		mediaType = board.MediaType(queueFile.Name)
+	}
+	resp.Header().Set("Content-Type", mediaType)
+
 	rangeHeader := req.Header.Get("Range")
 
 	startRange := 0
@@ -285,18 +473,18 @@ func (p *HTTPPlayer) servePlay(resp http.ResponseWriter, req *http.Request) {
 
 	if filePath == "" {
 
-		log.Println(p.Config.DownloadURL+p.sosach.Queue[position].Name, " not in cache, making following request: ", p.Config.BoardAddress+p.sosach.Queue[position].Path)
+		fileURL := p.sosach.FileURL(queueFile)
+		log.Println(queueFile.Name, " not in cache, making following request: ", fileURL)
 
-		outReq, err := http.NewRequest("GET", p.Config.BoardAddress+p.sosach.Queue[position].Path, nil)
+		outReq, err := p.client.NewRequest(req.Context(), fileURL)
 		if err != nil {
 			log.Println("Error on creating outgoing request ", err)
-			log.Println("Removing ", p.sosach.Queue[position].Name, " from queue")
-			p.sosach.Queue = append(p.sosach.Queue[:position], p.sosach.Queue[position+1:]...)
+			log.Println("Removing ", queueFile.Name, " from queue, it has invalid link")
+			p.sosach.RemoveFile(position)
+			http.Error(resp, "Invalid link to file on origin", http.StatusInternalServerError)
+			return
 		}
 
-		outReq.Header.Set("User-Agent", p.Config.BrowserUserAgent)
-		outReq.AddCookie(&http.Cookie{Name: "__cfduid", Value: p.Config.Cookie})
-
 		outReq.Header.Add("Range", rangeHeader)
 
 		if req.Header.Get("If-Range") != "" {
@@ -312,16 +500,40 @@ func (p *HTTPPlayer) servePlay(resp http.ResponseWriter, req *http.Request) {
 
 		log.Println("Created temporary file ", file.Name())
 
-		outerResp, err := http.DefaultClient.Do(outReq)
-
-		log.Println("Get response ", outerResp.StatusCode, "with rangeHeader", rangeHeader)
-
+		var outerResp *http.Response
+		if transport := p.sosach.Transport(queueFile); transport != nil {
+			outerResp, err = transport.RoundTrip(outReq)
+		} else {
+			outerResp, err = p.client.Do(outReq)
+		}
 		if err != nil {
 			log.Println("Error on doing outgoing reqeuest: ", err)
+			http.Error(resp, "Error on downloading file from origin", http.StatusBadGateway)
+			return
 		}
 
+		log.Println("Get response ", outerResp.StatusCode, "with rangeHeader", rangeHeader)
+
 		defer outerResp.Body.Close()
 
+		// Only media content is passed to client and saved to cache
+		if board.IsChallenge(outerResp) {
+			log.Println("Origin responded with Cloudflare challenge instead of ", queueFile.Name)
+			http.Error(resp, "Origin is blocked by Cloudflare challenge, try again later", http.StatusBadGateway)
+			return
+		}
+		if outerResp.StatusCode == http.StatusNotFound || outerResp.StatusCode == http.StatusGone {
+			log.Println("Removing ", queueFile.Name, " from queue, it's not found on origin")
+			p.sosach.RemoveFile(position)
+			http.Error(resp, "File is not found on origin", http.StatusNotFound)
+			return
+		}
+		if outerResp.StatusCode != http.StatusOK && outerResp.StatusCode != http.StatusPartialContent {
+			log.Println("Unexpected response status on downloading ", queueFile.Name, ": ", outerResp.Status)
+			http.Error(resp, "Error on downloading file from origin", http.StatusBadGateway)
+			return
+		}
+
 		resp.Header().Add("Last-Modified", outerResp.Header.Get("Last-Modified"))
 		resp.Header().Add("Expires", time.Now().Add(8760*time.Hour).Format(time.RFC1123))
 		resp.Header().Add("Content-Length", outerResp.Header.Get("Content-Length"))
@@ -342,25 +554,27 @@ func (p *HTTPPlayer) servePlay(resp http.ResponseWriter, req *http.Request) {
 
 		if err != nil {
 			log.Println("Error while downloading/uploading: ", err)
-		} else if startRange == 0 && endRange == 0 {
+		} else if startRange == 0 && endRange == 0 && outerResp.StatusCode == http.StatusOK {
+			cacheDir := p.cacheDirectory(queueFile)
+			cachePath := cacheDir + string(os.PathSeparator) + queueFile.Name
 
 			// Check if cache directory exist and create it not. If indicated path is file istead of directory, show alert and stop
-			dir, err := os.Stat(p.Config.SaveDirectory + string(os.PathSeparator) + "src" + string(os.PathSeparator) + p.sosach.Queue[position].Thread)
+			dir, err := os.Stat(cacheDir)
 			if err != nil {
 				log.Println("Could not stat directory for saving webm. Trying create a new one.")
-				err := os.MkdirAll(p.Config.SaveDirectory+string(os.PathSeparator)+"src"+string(os.PathSeparator)+p.sosach.Queue[position].Thread, 0755)
+				err := os.MkdirAll(cacheDir, 0755)
 				if err != nil {
 					log.Println("Could not create cache directory: ", err)
 				} else {
-					log.Println("Creating new directory ", p.Config.SaveDirectory+string(os.PathSeparator)+"src"+string(os.PathSeparator)+p.sosach.Queue[position].Thread)
-					dir, _ = os.Stat(p.Config.SaveDirectory + string(os.PathSeparator) + "src" + string(os.PathSeparator) + p.sosach.Queue[position].Thread)
+					log.Println("Creating new directory ", cacheDir)
+					dir, _ = os.Stat(cacheDir)
 				}
 			}
 
 			if !dir.IsDir() {
 				log.Println("Error during save file to cache: indicated path not a directory.")
 			} else {
-				err = os.Rename(file.Name(), p.Config.SaveDirectory+string(os.PathSeparator)+"src"+string(os.PathSeparator)+p.sosach.Queue[position].Thread+string(os.PathSeparator)+p.sosach.Queue[position].Name)
+				err = os.Rename(file.Name(), cachePath)
 				if err != nil {
 					log.Println("Error on saving file to cache: ", err)
 				} else {
@@ -371,13 +585,13 @@ func (p *HTTPPlayer) servePlay(resp http.ResponseWriter, req *http.Request) {
 					if err != nil {
 						log.Println("Error on parsing \"Last-Modified\" header from board response: ", err)
 					} else {
-						os.Chtimes(p.Config.SaveDirectory+string(os.PathSeparator)+"src"+string(os.PathSeparator)+p.sosach.Queue[position].Thread+string(os.PathSeparator)+p.sosach.Queue[position].Name, modifiedTime, modifiedTime)
+						os.Chtimes(cachePath, modifiedTime, modifiedTime)
 					}
 					//Add file to files cache map
-					p.addFileToCache(p.sosach.Queue[position].Name, p.Config.SaveDirectory+string(os.PathSeparator)+"src"+string(os.PathSeparator)+p.sosach.Queue[position].Thread+string(os.PathSeparator)+p.sosach.Queue[position].Name)
+					p.addFileToCache(queueFile, cachePath)
 
 					//Change file permissions to allow Nginx or someone access file
-					err = os.Chmod(p.Config.SaveDirectory+string(os.PathSeparator)+"src"+string(os.PathSeparator)+p.sosach.Queue[position].Thread+string(os.PathSeparator)+p.sosach.Queue[position].Name, 0777)
+					err = os.Chmod(cachePath, 0777)
 					if err != nil {
 						log.Println("Something very strange: error during changing permissions on new file: ", err)
 					}
@@ -469,7 +683,7 @@ func (p *HTTPPlayer) Play(resp http.ResponseWriter, req *http.Request) {
 
 	log.Println("Reuqest with sessionID: ", sessionID)
 	log.Println("Queue position: ", position)
-	log.Println("Queue length: ", len(p.sosach.Queue))
+	log.Println("Queue length: ", p.sosach.Queue.Len())
 
 	/*
 		err = p.servePlay(resp, sessionID, move)
@@ -478,13 +692,17 @@ func (p *HTTPPlayer) Play(resp http.ResponseWriter, req *http.Request) {
 		}*/
 }
 
-func (p *HTTPPlayer) ListenAndServe() error {
+// Function returns handler of all player pages, e.g. to embed player into other HTTP server.
+func (p *HTTPPlayer) Handler() http.Handler {
+	mux := http.NewServeMux()
 
-	http.HandleFunc("/play/"+p.Config.SaveDirectory+"/", p.servePlay)
+	mux.HandleFunc("/play/"+p.Config.SaveDirectory+"/", p.servePlay)
 
-	http.HandleFunc("/play/", p.Play)
+	mux.HandleFunc("/play/", p.Play)
 
-	http.HandleFunc("/", func(resp http.ResponseWriter, req *http.Request) {
+	mux.HandleFunc("/status", p.serveStatus)
+
+	mux.HandleFunc("/", func(resp http.ResponseWriter, req *http.Request) {
 		resp.Header().Set("Content-Type", "text/html")
 		resp.Header().Set("charset", "utf-8")
 		pageContent := `
@@ -572,7 +790,7 @@ func (p *HTTPPlayer) ListenAndServe() error {
            xmlhttp.onreadystatechange = function() {
             if (xmlhttp.readyState == XMLHttpRequest.DONE ) {
               if(xmlhttp.status == 200){
-                 document.getElementById(target).innerHTML = xmlhttp.responseText;
+                 document.getElementById(target).textContent = xmlhttp.responseText;
                   setTimeout(callback, 500);
                 }
            else if(xmlhttp.status == 400) {
@@ -588,9 +806,43 @@ func (p *HTTPPlayer) ListenAndServe() error {
            xmlhttp.send();
          }
 		
+		    function escapeHTML (text) {
+				return String(text).replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;").replace(/"/g, "&quot;");
+			}
+
+		    function formatDuration (seconds) {
+				var secs = seconds % 60;
+				return Math.floor(seconds / 60) + ":" + (secs < 10 ? "0" : "") + secs;
+			}
+
 		    function updateVideoInfo () {
-				info = JSON.parse(document.getElementById("hidden").innerHTML);
-				document.getElementById("info").innerHTML = "Link to original video: <a href=\"https://2ch.hk/b/"+info.path+"\">https://2ch.hk/b/"+info.path+"</a><br/>Link to original post: <a href=\"https://2ch.hk/b/res/"+info.thread+".html#"+info.post+"\">https://2ch.hk/b/res"+info.thread+".html#"+info.post+"</a>";				
+				info = JSON.parse(document.getElementById("hidden").textContent);
+				var links = "";
+				var details = [];
+				if (info.subject) {
+					details.push(escapeHTML(info.subject));
+				}
+				if (info.duration > 0) {
+					details.push(formatDuration(info.duration));
+				}
+				if (info.width > 0 && info.height > 0) {
+					details.push(info.width + "x" + info.height);
+				}
+				if (info.size > 0) {
+					details.push((info.size / 1048576).toFixed(1) + " MB");
+				}
+				if (details.length > 0) {
+					links += details.join(", ") + "<br/>";
+				}
+				links += "<a href=\"play/` + p.Config.SaveDirectory + `/"+escapeHTML(info.path)+"\" download=\""+escapeHTML(info.fullname || info.name)+"\">Download "+escapeHTML(info.fullname || info.name)+"</a><br/>";
+				if (/^https?:\/\//.test(info.url)) {
+					links += "Link to original video: <a href=\""+escapeHTML(info.url)+"\">"+escapeHTML(info.url)+"</a><br/>";
+				}
+				if (/^https?:\/\//.test(info.postUrl)) {
+					links += "Link to original post: <a href=\""+escapeHTML(info.postUrl)+"\">"+escapeHTML(info.postUrl)+"</a>";
+				}
+				document.getElementById("info").innerHTML = links;
+				document.getElementById('video_player').poster=info.thumbnailUrl;
 				document.getElementById('video_player').src='play/` + p.Config.SaveDirectory + `/'+info.path;
 			}
 			
@@ -618,11 +870,78 @@ func (p *HTTPPlayer) ListenAndServe() error {
 		io.WriteString(resp, pageContent)
 	})
 
-	err := http.ListenAndServe(":"+p.Config.Port, nil)
+	return mux
+}
+
+// Function starts board watcher, sessions cleaner and HTTP server on configured port. Everything is
+// stopped when context is canceled or Stop is called.
+func (p *HTTPPlayer) Start(ctx context.Context) error {
+	p.lifecycle.Lock()
+	defer p.lifecycle.Unlock()
+	if p.lifecycle.cancel != nil {
+		return errors.New("Player is already started")
+	}
 
+	listener, err := net.Listen("tcp", ":"+p.Config.Port)
 	if err != nil {
-		log.Fatal("Error on creating listener: ", err)
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	err = p.sosach.Start(ctx)
+	if err != nil {
+		cancel()
+		listener.Close()
+		return err
 	}
 
+	p.lifecycle.cancel = cancel
+	p.lifecycle.server = &http.Server{Handler: p.Handler(), BaseContext: func(net.Listener) context.Context { return ctx }}
+	p.lifecycle.served = make(chan error, 1)
+
+	p.lifecycle.wait.Add(2)
+	go p.startSessionsCleaner(ctx)
+	go func(server *http.Server, served chan error) {
+		defer p.lifecycle.wait.Done()
+		err := server.Serve(listener)
+		if err == http.ErrServerClosed {
+			err = nil
+		}
+		served <- err
+	}(p.lifecycle.server, p.lifecycle.served)
+
+	// Stop server if parent context is canceled
+	go func(server *http.Server) {
+		<-ctx.Done()
+		server.Close()
+	}(p.lifecycle.server)
+
 	return nil
 }
+
+// Function to stop HTTP server, background goroutines and board watcher. Active downloads are aborted.
+func (p *HTTPPlayer) Stop() error {
+	p.lifecycle.Lock()
+	defer p.lifecycle.Unlock()
+	if p.lifecycle.cancel == nil {
+		return nil
+	}
+	p.lifecycle.cancel()
+	p.lifecycle.wait.Wait()
+	p.lifecycle.cancel = nil
+	return p.sosach.Stop()
+}
+
+// Function to start player and serve requests until server is stopped.
+func (p *HTTPPlayer) ListenAndServe() error {
+	err := p.Start(context.Background())
+	if err != nil {
+		log.Fatal("Error on creating listener: ", err)
+	}
+
+	p.lifecycle.Lock()
+	served := p.lifecycle.served
+	p.lifecycle.Unlock()
+
+	return <-served
+}
@@ -5,21 +5,107 @@ package main
 
 import (
 	"SaaS/HTTPPlayer"
+	"SaaS/board"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"io/ioutil"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// Type represents single board(or any other source of files) in configuration file
+type boardConfig struct {
+	Name   string
+	Engine string
+	Weight int
+
+	// Thread matching rules of imageboards, overrides global rules
+	Rules *board.Rules
+
+	// Extensions of video files, overrides global extensions
+	Extensions []string
+
+	// Cookies sent only to sites of this board
+	Cookies map[string]string
+
+	// 2ch
+	JSONUrl      string
+	DownloadURL  string
+	BoardAddress string
+	Pages        int
+
+	// 4chan and vichan
+	SiteURL  string
+	APIURL   string
+	MediaURL string
+	Board    string
+
+	// Local directory
+	Directory string
+
+	// RSS/Atom
+	Feeds []string
+}
+
 // Type represents structure of configuration file(JSON)
 type configFile struct {
 	Port             string
 	JSONUrl          string
 	BoardAddress     string
 	DownloadURL      string
+	Pages            int
 	BrowserUserAgent string
 	Cookie           string
+	CookieClearance  string
 	SaveDirectory    string
+	Rules            *board.Rules
+	Extensions       []string
+	Boards           []boardConfig
+
+	// Cookies sent to BoardAddress(2ch) and headers sent with every request to origin
+	Cookies map[string]string
+	Headers map[string]string
+
+	// Proxy for all requests and optional separate proxy for media downloads(http, https or socks5 URL)
+	Proxy      string
+	MediaProxy string
+
+	// Limits of requests to origin: API requests per second and concurrent media downloads
+	APIRate      float64
+	MaxDownloads int
+
+	// Limits of queue: maximum number of files and maximum age of file(e.g. "72h")
+	QueueCapacity int
+	QueueMaxAge   string
+
+	// Time during which the same file from other threads is skipped as repost(e.g. "24h"), "0" disables it
+	RepostWindow string
+
+	// Time after which thread not found on board is forgotten with it's files(e.g. "30m")
+	DeadThreadGrace string
+
+	// Number of threads fetched in parallel and timeout of single request(e.g. "30s")
+	RefreshWorkers int
+	RequestTimeout string
+
+	// Limits of thread polling interval(e.g. "1m" and "15m")
+	PollMinInterval string
+	PollMaxInterval string
+}
+
+// Interface of sources with configurable thread matching rules
+type rulesSetter interface {
+	SetRules(rules board.Rules) error
+}
+
+// Interface of sources with configurable extensions of video files
+type extensionsSetter interface {
+	SetExtensions(extensions []string)
 }
 
 // Function read configuration and set return configFile type
@@ -37,6 +123,196 @@ func readConfig(confFilePath *string) (*configFile, error) {
 	return config, nil
 }
 
+// Function creates source for board from configuration and applies thread matching rules and extensions.
+func newSource(client *board.Client, config *configFile, boardConf boardConfig) (board.Source, error) {
+	source, err := newEngineSource(client, config, boardConf)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := config.Rules
+	if boardConf.Rules != nil {
+		rules = boardConf.Rules
+	} else if rules != nil && len(config.Boards) != 0 && len(rules.Allow) != 0 {
+		// Thread numbers are different on every board, so global allow-list is used only for single board
+		log.Println("Global allow-list is ignored for board ", boardConf.Name, ", set it in rules of board")
+		boardRules := *rules
+		boardRules.Allow = nil
+		rules = &boardRules
+	}
+	if setter, ok := source.(rulesSetter); ok && rules != nil {
+		err = setter.SetRules(*rules)
+		if err != nil {
+			return nil, errors.New("Error in rules of board " + boardConf.Name + ": " + err.Error())
+		}
+	}
+
+	extensions := config.Extensions
+	if boardConf.Extensions != nil {
+		extensions = boardConf.Extensions
+	}
+	if setter, ok := source.(extensionsSetter); ok && extensions != nil {
+		setter.SetExtensions(extensions)
+	}
+	return source, nil
+}
+
+// Function creates source for board engine. Empty 2ch addresses are built from BoardAddress and name of board.
+func newEngineSource(client *board.Client, config *configFile, boardConf boardConfig) (board.Source, error) {
+	switch boardConf.Engine {
+	case "", "2ch":
+		if boardConf.BoardAddress == "" {
+			boardConf.BoardAddress = config.BoardAddress
+		}
+		if boardConf.DownloadURL == "" {
+			boardConf.DownloadURL = boardConf.BoardAddress + boardConf.Name + "/"
+		}
+		if boardConf.JSONUrl == "" {
+			boardConf.JSONUrl = boardConf.DownloadURL + "index.json"
+		}
+		source := board.NewMakaba(client, boardConf.JSONUrl, boardConf.DownloadURL, boardConf.BoardAddress)
+		source.SetPages(boardConf.Pages)
+		return source, nil
+	case "4chan":
+		if boardConf.Board == "" {
+			boardConf.Board = boardConf.Name
+		}
+		return board.NewFourChan(client, boardConf.SiteURL, boardConf.APIURL, boardConf.MediaURL, boardConf.Board), nil
+	case "vichan":
+		if boardConf.Board == "" {
+			boardConf.Board = boardConf.Name
+		}
+		return board.NewVichan(client, boardConf.SiteURL, boardConf.Board), nil
+	case "directory":
+		return board.NewDirectory(boardConf.Directory)
+	case "feed":
+		return board.NewFeed(client, boardConf.Feeds...), nil
+	}
+	return nil, errors.New("Unknown engine " + boardConf.Engine + " of board " + boardConf.Name)
+}
+
+// Function returns addresses of sites which board requests, to send cookies of board only there.
+func boardOrigins(config *configFile, boardConf boardConfig) []string {
+	switch boardConf.Engine {
+	case "", "2ch":
+		if boardConf.BoardAddress != "" {
+			return []string{boardConf.BoardAddress}
+		}
+		return []string{config.BoardAddress}
+	case "4chan":
+		return []string{boardConf.SiteURL, boardConf.APIURL, boardConf.MediaURL}
+	case "vichan":
+		return []string{boardConf.SiteURL}
+	case "feed":
+		return boardConf.Feeds
+	}
+	return nil
+}
+
+// Function to set cookies for all origins. Errors are only logged, so other cookies are still set.
+func setCookies(client *board.Client, origins []string, cookies map[string]string) {
+	for _, origin := range origins {
+		if origin == "" {
+			continue
+		}
+		for name, value := range cookies {
+			err := client.SetCookie(origin, name, value)
+			if err != nil {
+				log.Println("Error on setting cookie: ", err)
+			}
+		}
+	}
+}
+
+// Function to set cookies and headers from configuration to client. Global cookies are sent only to 2ch
+// BoardAddress, cookies of board only to it's sites.
+func setCredentials(client *board.Client, config *configFile) {
+	cookies := make(map[string]string)
+	if config.Cookie != "" {
+		cookies["__cfduid"] = config.Cookie
+	}
+	if config.CookieClearance != "" {
+		cookies["cf_clearance"] = config.CookieClearance
+	}
+	for name, value := range config.Cookies {
+		cookies[name] = value
+	}
+	origin := config.BoardAddress
+	if origin == "" {
+		origin = config.DownloadURL
+	}
+	setCookies(client, []string{origin}, cookies)
+
+	for _, boardConf := range config.Boards {
+		setCookies(client, boardOrigins(config, boardConf), boardConf.Cookies)
+	}
+	for name, value := range config.Headers {
+		client.SetHeader(name, value)
+	}
+}
+
+// Function to set fresh credentials from configuration file on every SIGHUP, e.g. to unblock board after
+// Cloudflare challenge. Works until context is canceled.
+func reloadCredentials(ctx context.Context, configFilePath *string, client *board.Client) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+		log.Println("Reloading credentials from ", *configFilePath)
+		config, err := readConfig(configFilePath)
+		if err != nil {
+			log.Println("Error on reading config file: ", err)
+			continue
+		}
+		setCredentials(client, config)
+	}
+}
+
+// Function creates player for all boards from configuration or for single 2ch board, if there is no boards list.
+func newPlayer(config *configFile) (*HTTPPlayer.HTTPPlayer, error) {
+	client := board.NewClient(config.BrowserUserAgent)
+	setCredentials(client, config)
+	client.SetLimits(config.APIRate, config.MaxDownloads)
+	if config.Proxy != "" {
+		err := client.SetProxy(config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.MediaProxy != "" {
+		err := client.SetMediaProxy(config.MediaProxy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(config.Boards) == 0 {
+		source, err := newSource(client, config, boardConfig{JSONUrl: config.JSONUrl, DownloadURL: config.DownloadURL, BoardAddress: config.BoardAddress, Pages: config.Pages})
+		if err != nil {
+			return nil, err
+		}
+		return HTTPPlayer.NewHTTPPlayerWithSource(config.SaveDirectory, config.Port, client, source)
+	}
+
+	sources := board.NewAggregate()
+	for _, boardConf := range config.Boards {
+		source, err := newSource(client, config, boardConf)
+		if err != nil {
+			return nil, err
+		}
+		err = sources.Add(boardConf.Name, boardConf.Weight, source)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return HTTPPlayer.NewHTTPPlayerWithSource(config.SaveDirectory, config.Port, client, sources)
+}
+
 func main() {
 	configFilePath := flag.String("conf", "config.json", "indicate path to config.json")
 	flag.Parse()
@@ -46,11 +322,87 @@ func main() {
 		log.Fatalln("Error on reading config file: ", err)
 	}
 
-	player, err := HTTPPlayer.NewHTTPPlayer(config.SaveDirectory, config.Cookie, config.BrowserUserAgent, config.BoardAddress, config.DownloadURL, config.JSONUrl, config.Port)
+	player, err := newPlayer(config)
 	if err != nil {
 		log.Fatalln("Error on creating HTTP Player: ", err)
 	}
 
-	player.ListenAndServe()
+	queueCapacity := config.QueueCapacity
+	if queueCapacity == 0 {
+		queueCapacity = board.DefaultQueueCapacity
+	}
+	var queueMaxAge time.Duration
+	if config.QueueMaxAge != "" {
+		queueMaxAge, err = time.ParseDuration(config.QueueMaxAge)
+		if err != nil {
+			log.Fatalln("Error on parsing queue max age: ", err)
+		}
+	}
+	player.Board().SetQueueLimits(queueCapacity, queueMaxAge)
 
+	if config.RepostWindow != "" {
+		repostWindow, err := time.ParseDuration(config.RepostWindow)
+		if err != nil {
+			log.Fatalln("Error on parsing repost window: ", err)
+		}
+		player.Board().SetRepostWindow(repostWindow)
+	}
+
+	if config.DeadThreadGrace != "" {
+		deadThreadGrace, err := time.ParseDuration(config.DeadThreadGrace)
+		if err != nil {
+			log.Fatalln("Error on parsing dead thread grace period: ", err)
+		}
+		player.Board().SetDeadThreadGrace(deadThreadGrace)
+	}
+
+	refreshWorkers, requestTimeout := board.DefaultRefreshWorkers, board.DefaultRequestTimeout
+	if config.RefreshWorkers != 0 {
+		refreshWorkers = config.RefreshWorkers
+	}
+	if config.RequestTimeout != "" {
+		requestTimeout, err = time.ParseDuration(config.RequestTimeout)
+		if err != nil {
+			log.Fatalln("Error on parsing request timeout: ", err)
+		}
+	}
+	err = player.Board().SetRefreshWorkers(refreshWorkers, requestTimeout)
+	if err != nil {
+		log.Fatalln("Error on setting refresh workers: ", err)
+	}
+
+	pollMin, pollMax := board.DefaultPollMinInterval, board.DefaultPollMaxInterval
+	if config.PollMinInterval != "" {
+		pollMin, err = time.ParseDuration(config.PollMinInterval)
+		if err != nil {
+			log.Fatalln("Error on parsing minimum poll interval: ", err)
+		}
+	}
+	if config.PollMaxInterval != "" {
+		pollMax, err = time.ParseDuration(config.PollMaxInterval)
+		if err != nil {
+			log.Fatalln("Error on parsing maximum poll interval: ", err)
+		}
+	}
+	err = player.Board().SetPollIntervals(pollMin, pollMax)
+	if err != nil {
+		log.Fatalln("Error on setting poll intervals: ", err)
+	}
+
+	// Stop player on shutdown, board state is saved to restore queue on next start
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = player.Start(ctx)
+	if err != nil {
+		log.Fatalln("Error on starting HTTP Player: ", err)
+	}
+	go reloadCredentials(ctx, configFilePath, player.Client())
+
+	<-ctx.Done()
+	log.Println("Stopping HTTP Player")
+	err = player.Stop()
+	if err != nil {
+		log.Println("Error on saving board state: ", err)
+	}
 }
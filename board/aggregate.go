@@ -0,0 +1,158 @@
+package board
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Type to describe single board of aggregate
+type aggregated struct {
+	name   string
+	weight int
+	source Source
+}
+
+// Type to merge several boards(or any other sources) into one. Threads are prefixed with board name,
+// files are marked with board name, so Board could keep them in one cache and one queue.
+type Aggregate struct {
+	sources []*aggregated
+	byName  map[string]*aggregated
+}
+
+// Generates new empty aggregate.
+func NewAggregate() *Aggregate {
+	return &Aggregate{byName: make(map[string]*aggregated)}
+}
+
+// Function to add board with indicated name and weight. Weight is a number of files from this board
+// added to queue in a row, new files of board wait for files of other boards to be mixed with them.
+func (a *Aggregate) Add(name string, weight int, source Source) error {
+	if name == "" || strings.Contains(name, "/") {
+		return errors.New("Board name should be non-empty and without slashes: " + name)
+	}
+	if _, ok := a.byName[name]; ok {
+		return errors.New("Board already added: " + name)
+	}
+	if weight < 1 {
+		weight = 1
+	}
+	board := &aggregated{name, weight, source}
+	a.sources = append(a.sources, board)
+	a.byName[name] = board
+	return nil
+}
+
+// Function returns names of boards in order they were added.
+func (a *Aggregate) Boards() []string {
+	boards := make([]string, len(a.sources))
+	for i, board := range a.sources {
+		boards[i] = board.name
+	}
+	return boards
+}
+
+// Function returns weight of board, 1 for unknown boards.
+func (a *Aggregate) Weight(board string) int {
+	if aggregated, ok := a.byName[board]; ok {
+		return aggregated.weight
+	}
+	return 1
+}
+
+// Function to split aggregate thread to board and thread of board. Threads of boards removed from
+// configuration, e.g. restored from state file, are not found, so they are forgotten after grace period.
+func (a *Aggregate) split(thread string) (*aggregated, string, error) {
+	parts := strings.SplitN(thread, "/", 2)
+	if len(parts) != 2 {
+		log.Println("Thread ", thread, " has no board")
+		return nil, "", ErrNotFound
+	}
+	board, ok := a.byName[parts[0]]
+	if !ok {
+		log.Println("Board ", parts[0], " of thread ", thread, " is not configured")
+		return nil, "", ErrNotFound
+	}
+	return board, parts[1], nil
+}
+
+// Function returns threads of all boards. Error is returned only if all boards are failed.
+func (a *Aggregate) Threads(ctx context.Context) ([]string, error) {
+	var threads []string
+	var lastErr error
+	failed := 0
+	for _, board := range a.sources {
+		boardThreads, err := board.source.Threads(ctx)
+		if err == ErrNotModified {
+			continue
+		}
+		if err != nil {
+			log.Println("Error on getting threads of board ", board.name, ": ", err)
+			lastErr = err
+			failed++
+			continue
+		}
+		for _, thread := range boardThreads {
+			threads = append(threads, board.name+"/"+thread)
+		}
+	}
+	if failed != 0 && failed == len(a.sources) {
+		return nil, lastErr
+	}
+	return threads, nil
+}
+
+// Function returns files of thread marked with board name.
+func (a *Aggregate) Files(ctx context.Context, thread string) ([]FileInfo, error) {
+	board, boardThread, err := a.split(thread)
+	if err != nil {
+		return nil, err
+	}
+	files, err := board.source.Files(ctx, boardThread)
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		files[i].Board = board.name
+	}
+	return files, nil
+}
+
+// Function to forget state of thread if it's board keeps one.
+func (a *Aggregate) Forget(thread string) {
+	board, boardThread, err := a.split(thread)
+	if err != nil {
+		return
+	}
+	if source, ok := board.source.(forgetter); ok {
+		source.Forget(boardThread)
+	}
+}
+
+// Function returns transport of board which serves files without network, nil for other boards.
+func (a *Aggregate) transport(file FileInfo) http.RoundTripper {
+	if board, ok := a.byName[file.Board]; ok {
+		if source, ok := board.source.(fileServer); ok {
+			return source.transport(file)
+		}
+	}
+	return nil
+}
+
+// Function returns link to file on it's board.
+func (a *Aggregate) FileURL(file FileInfo) string {
+	if board, ok := a.byName[file.Board]; ok {
+		return board.source.FileURL(file)
+	}
+	return ""
+}
+
+// Function returns link to post on it's board.
+func (a *Aggregate) PostURL(file FileInfo) string {
+	if board, ok := a.byName[file.Board]; ok {
+		return board.source.PostURL(file)
+	}
+	return ""
+}
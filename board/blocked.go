@@ -0,0 +1,154 @@
+package board
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Type to keep threads paused because their host is blocked by Cloudflare challenge
+type pausedHost struct {
+	err     *BlockedError
+	since   time.Time
+	threads map[string]bool
+	// Number of checks in a row which found host still blocked and time of next check
+	failures int
+	retry    time.Time
+}
+
+// Type to keep blocked hosts of board. Only threads of blocked host are paused, threads of other hosts,
+// e.g. of other boards in aggregate, are polled as usual.
+type blockedHosts struct {
+	sync.Mutex
+	hosts map[string]*pausedHost
+	// Channel to wake up watcher when host is unblocked
+	wake chan struct{}
+}
+
+// Function returns delay before next attempt after failures in a row. Delay grows exponentially with
+// random jitter, so origin is not hammered while it's down.
+func retryDelay(failures int) time.Duration {
+	delay := retryMinDelay
+	for i := 1; i < failures && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// Function to pause thread because it's host is blocked, empty thread means scan of board. Threads of host
+// are resumed when fresh credentials are set or when host is checked again and challenge is gone.
+func (b *Board) hostBlocked(ctx context.Context, thread string, err *BlockedError) {
+	b.blocked.Lock()
+	defer b.blocked.Unlock()
+	if b.blocked.hosts == nil {
+		b.blocked.hosts = make(map[string]*pausedHost)
+	}
+	now := time.Now()
+	host, ok := b.blocked.hosts[err.Host]
+	if !ok || host.err.unblocked != err.unblocked {
+		host = &pausedHost{err: err, since: now, threads: make(map[string]bool)}
+		b.blocked.hosts[err.Host] = host
+		log.Println("Polling of ", err.Host, " is paused until fresh credentials are set: ", err)
+		go b.waitUnblocked(ctx, err)
+	}
+	if thread != "" {
+		host.threads[thread] = true
+	}
+	// Threads polled together with check of host don't make delay longer
+	if !host.retry.After(now) {
+		host.failures++
+		host.retry = now.Add(retryDelay(host.failures))
+		log.Println("Next check of ", err.Host, " in ", host.retry.Sub(now).Round(time.Second), ", it's blocked ", host.failures, " times in a row")
+	}
+}
+
+// Function to resume threads of host when it's unblocked.
+func (b *Board) waitUnblocked(ctx context.Context, err *BlockedError) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-err.Unblocked():
+	}
+	b.blocked.Lock()
+	if host, ok := b.blocked.hosts[err.Host]; ok && host.err.unblocked == err.unblocked {
+		log.Println("Polling of ", err.Host, " is resumed")
+		delete(b.blocked.hosts, err.Host)
+	}
+	b.blocked.Unlock()
+	select {
+	case b.blocked.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Function returns threads which are not paused. When it's time to check blocked host again, one of it's
+// threads is returned to find out if challenge is gone.
+func (b *Board) activeThreads(threads []string, now time.Time) []string {
+	b.blocked.Lock()
+	defer b.blocked.Unlock()
+	if len(b.blocked.hosts) == 0 {
+		return threads
+	}
+	active := make([]string, 0, len(threads))
+	checked := make(map[*pausedHost]bool)
+	for _, thread := range threads {
+		host := b.pausedHost(thread)
+		if host == nil {
+			active = append(active, thread)
+		} else if !host.retry.After(now) && !checked[host] {
+			checked[host] = true
+			active = append(active, thread)
+		}
+	}
+	return active
+}
+
+// Function returns blocked host of paused thread, nil if thread is not paused. Lock should be held.
+func (b *Board) pausedHost(thread string) *pausedHost {
+	for _, host := range b.blocked.hosts {
+		if host.threads[thread] {
+			return host
+		}
+	}
+	return nil
+}
+
+// Function returns time of the nearest check of blocked host, zero time if there are no paused threads.
+func (b *Board) nextBlockedCheck() time.Time {
+	b.blocked.Lock()
+	defer b.blocked.Unlock()
+	var next time.Time
+	for _, host := range b.blocked.hosts {
+		if len(host.threads) != 0 && (next.IsZero() || host.retry.Before(next)) {
+			next = host.retry
+		}
+	}
+	return next
+}
+
+// Function to forget paused thread, e.g. if it's removed from cache.
+func (b *Board) forgetPaused(thread string) {
+	b.blocked.Lock()
+	defer b.blocked.Unlock()
+	for _, host := range b.blocked.hosts {
+		delete(host.threads, thread)
+	}
+}
+
+// Function returns time of first challenge of the earliest blocked host, zero time if board is not blocked.
+func (b *Board) blockedSince() time.Time {
+	b.blocked.Lock()
+	defer b.blocked.Unlock()
+	var since time.Time
+	for _, host := range b.blocked.hosts {
+		if since.IsZero() || host.since.Before(since) {
+			since = host.since
+		}
+	}
+	return since
+}
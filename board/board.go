@@ -2,14 +2,11 @@
 package board
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
-	"regexp"
-	"strconv"
 	"sync"
 	"time"
 )
@@ -20,287 +17,540 @@ type boardMap struct {
 	threads map[string]map[string]string
 }
 
-//type represent information about single webm file
+//type represent information about single video file
 type FileInfo struct {
 	Name   string `json:"name"`
 	Path   string `json:"path"`
 	Thread string `json:"thread"`
 	Post   string `json:"post"`
+	Board  string `json:"board"`
+	Type   string `json:"type"`
+	MD5    string `json:"md5"`
+
+	// Metadata provided by board, zero if unknown. Size is in bytes, Duration is in seconds,
+	// Timestamp is a unix time of post.
+	Size      int64  `json:"size"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Duration  int    `json:"duration"`
+	Fullname  string `json:"fullname"`
+	Thumbnail string `json:"thumbnail"`
+	Timestamp int64  `json:"timestamp"`
+	Subject   string `json:"subject"`
 }
 
-// Type to parse JSON-view of imageboard page
-type boardPage struct {
-	Threads []struct {
-		Thread_num string
-		Posts      []struct {
-			Comment string
-			Files   []struct {
-				Path string
-				Name string
-			}
-			Num int
-		}
-	}
-}
+// Limits of delay before retry of failed refresh
+const (
+	retryMinDelay = 10 * time.Second
+	retryMaxDelay = 10 * time.Minute
+)
 
-// Type to parse JSON-view of Main imageboard page. There is Number os posts in string
-// instead of interer as on other pages. That's really not what i expected
-type boardMainPage struct {
-	Threads []struct {
-		Thread_num string
-		Posts      []struct {
-			Comment string
-			Files   []struct {
-				Path string
-				Name string
-			}
-			Num string
-		}
-	}
+// Type represents health of board watcher
+type Status struct {
+	// Error of last failed refresh or thread poll, nil if board was never failed
+	LastError error
+	// Time of last failed refresh or thread poll
+	LastErrorTime time.Time
+	// Number of failed refreshes in a row, zero if last refresh was successful
+	Failures int
+	// Number of failed thread polls in a row, zero if last thread poll was successful
+	PollFailures int
+	// Time of last successful refresh
+	LastSuccess time.Time
+	// Some hosts of board are blocked by Cloudflare challenge and their polling is paused until fresh
+	// credentials are set
+	Blocked bool
+	// Time when the first of blocked hosts was blocked
+	BlockedSince time.Time
 }
 
 // Type to represent our view of imageboard state.
 type Board struct {
 
-	// Struct to save the configuration
-	config struct {
-		JSONUrl          string
-		DownloadURL      string
-		BrowserUserAgent string
-		Cookie           string
-		Cookieclearance  string
-	}
-
-	// Regexp to check if thread is a WEBM-thread
-	threadWebmRegexp *regexp.Regexp
-
-	// Regexp to check if file is webm
-	filenameWebmRegexp *regexp.Regexp
+	// Origin of threads and files
+	source Source
 
 	// Map to cache threads and WEBM files status, to know when new ones added
 	cache boardMap
 
 	// Watch queue
-	Queue []FileInfo
-}
+	Queue *Queue
 
-// Generates new board instance and fill default values.
-func NewBoard(JSONUrl, DownloadURL, BrowserUserAgent, Cookie string) (*Board, error) {
-	board := new(Board)
-	board.config.JSONUrl = JSONUrl
-	board.config.DownloadURL = DownloadURL
-	board.config.BrowserUserAgent = BrowserUserAgent
-	board.config.Cookie = Cookie
-
-	// If we didn't recieve cookies, get it from site
-	if board.config.Cookie == "" {
-		err := board.getCFCookie(board.config.DownloadURL)
-		if err != nil {
-			log.Println("Error cookie request: ", err)
-		}
+	// Channels of Subscribe callers
+	subscribers subscribers
+
+	// File to save board state, set by LoadState
+	stateFile string
+
+	// Threads cache or queue are changed since last save of state
+	changes struct {
+		sync.Mutex
+		changed bool
 	}
 
-	//Precompile regexp's for parsing threads content
-	board.threadWebmRegexp = regexp.MustCompile("([ШшWw][EeЕе][BbБб].*[MmМм])|([Цц][Уу][ИЙйи].*[Ьь])")
-	board.filenameWebmRegexp = regexp.MustCompile(".webm$")
+	// Polling schedule of threads
+	polls scheduler
 
-	//Struct to store target threads view
-	board.cache.threads = make(map[string]map[string]string)
+	// Hashes of queued files to skip reposts
+	reposts reposts
 
-	return board, nil
-}
+	// New files of weighted boards waiting to be released to queue
+	pending pendingFiles
 
-// Function get fresh CloudFlare cookie and set it to config variable atribure.
-func (b *Board) getCFCookie(URL string) error {
-	client := &http.Client{}
+	// Threads not found on board
+	dead deadThreads
 
-	req, err := http.NewRequest("GET", URL, nil)
-	if err != nil {
-		return err
-	}
+	// Hosts blocked by Cloudflare challenge and their paused threads
+	blocked blockedHosts
 
-	req.Header.Set("User-Agent", b.config.BrowserUserAgent)
+	// Settings of parallel thread refresh
+	refresh refreshConfig
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+	// Health of watcher
+	status struct {
+		sync.Mutex
+		Status
 	}
 
-	defer resp.Body.Close()
-	_, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+	// Control of watcher goroutine
+	lifecycle struct {
+		sync.Mutex
+		cancel context.CancelFunc
+		wait   sync.WaitGroup
 	}
-	b.config.Cookie = resp.Cookies()[0].Value
-	return nil
-
 }
 
-// function make GET request with UserAgent and CloudFlare cookie from config variable.
-func (b *Board) getUrl(URL string) (response []byte, err error) {
+// Generates new board instance for 2ch and fill default values.
+func NewBoard(JSONUrl, DownloadURL, BrowserUserAgent, Cookie string) (*Board, error) {
+	client := NewClient(BrowserUserAgent)
+	if Cookie != "" {
+		err := client.SetCookie(DownloadURL, "__cfduid", Cookie)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewBoardWithSource(NewMakaba(client, JSONUrl, DownloadURL, ""))
+}
 
-	req, err := http.NewRequest("GET", URL, nil)
-	if err != nil {
-		return nil, err
+// Generates new board instance which watches indicated source.
+func NewBoardWithSource(source Source) (*Board, error) {
+	if source == nil {
+		return nil, errors.New("Source is not indicated")
 	}
+	board := new(Board)
+	board.source = source
 
-	req.Header.Set("User-Agent", b.config.BrowserUserAgent)
-	req.AddCookie(&http.Cookie{Name: "__cfduid", Value: b.config.Cookie})
-	req.AddCookie(&http.Cookie{Name: "cf_clearance", Value: b.config.Cookieclearance})
+	//Struct to store target threads view
+	board.cache.threads = make(map[string]map[string]string)
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
+	board.Queue = NewQueue(DefaultQueueCapacity, 0)
+	board.polls.setIntervals(DefaultPollMinInterval, DefaultPollMaxInterval)
+	board.reposts.setWindow(DefaultRepostWindow)
+	board.dead.grace = DefaultDeadThreadGrace
+	board.blocked.wake = make(chan struct{}, 1)
+	board.refresh.workers = DefaultRefreshWorkers
+	board.refresh.timeout = DefaultRequestTimeout
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	return board, nil
+}
+
+// Function to set limits of thread polling interval. Threads with new files are polled more often, down to
+// min, and quiet threads less often, up to max.
+func (b *Board) SetPollIntervals(min, max time.Duration) error {
+	if min <= 0 || max <= 0 {
+		return errors.New("Poll intervals should be positive")
+	}
+	if max < min {
+		return errors.New("Maximum poll interval is less than minimum")
 	}
+	b.polls.setIntervals(min, max)
+	return nil
+}
+
+// Function returns absolute URL to download file from origin.
+func (b *Board) FileURL(file FileInfo) string {
+	return b.source.FileURL(file)
+}
 
-	if resp.StatusCode != 200 {
-		return body, errors.New("Response status is " + resp.Status)
+// Function returns transport to download file if source serves files without network, e.g. local
+// directory, or nil if file should be downloaded with Client.
+func (b *Board) Transport(file FileInfo) http.RoundTripper {
+	if source, ok := b.source.(fileServer); ok {
+		return source.transport(file)
 	}
-	return body, nil
+	return nil
+}
+
+// Function returns link to post with file on origin.
+func (b *Board) PostURL(file FileInfo) string {
+	return b.source.PostURL(file)
 }
 
-// Function to remove thread from threads map with RW lock.
+// Function to add thread to threads map with RW lock.
 func (b *Board) addThread(num string) error {
-	b.cache.RLock()
+	b.cache.Lock()
 	b.cache.threads[num] = make(map[string]string)
-	b.cache.RUnlock()
+	b.cache.Unlock()
+	b.stateChanged()
+	b.publish(Event{Type: ThreadDiscovered, Thread: num})
 	return nil
 }
 
 // Functuon delete dead thread from board state cache.
 func (b *Board) deleteThread(num string) error {
-	b.cache.RLock()
+	b.cache.Lock()
 	delete(b.cache.threads, num)
-	b.cache.RUnlock()
+	b.cache.Unlock()
+	b.stateChanged()
+	b.polls.forget(num)
+	b.dead.Lock()
+	delete(b.dead.threads, num)
+	b.dead.Unlock()
+	b.forgetPaused(num)
+	if source, ok := b.source.(forgetter); ok {
+		source.Forget(num)
+	}
+	b.publish(Event{Type: ThreadDied, Thread: num})
 	return nil
 }
 
 // Function to check if thread already exist in cache or not.
 func (b *Board) isThread(num string) bool {
 	b.cache.RLock()
-	if _, ok := b.cache.threads[num]; !ok {
-		b.cache.RUnlock()
-		return false
-	}
-	b.cache.RUnlock()
-	return true
+	defer b.cache.RUnlock()
+	_, ok := b.cache.threads[num]
+	return ok
 }
 
-// Fuction return current board state cache to iterate over.
-func (b *Board) getThreadsMap() map[string]map[string]string {
+// Fuction return list of threads from board state cache to iterate over.
+func (b *Board) getThreadsList() []string {
 	b.cache.RLock()
 	defer b.cache.RUnlock()
-	return b.cache.threads
+	threads := make([]string, 0, len(b.cache.threads))
+	for num := range b.cache.threads {
+		threads = append(threads, num)
+	}
+	return threads
 }
 
 // Function to check if file already added to cache or not.
 func (b *Board) isFile(thread, name string) bool {
 	b.cache.RLock()
-	if b.cache.threads[thread][name] == "" {
-		return false
-	}
-	return true
+	defer b.cache.RUnlock()
+	return b.cache.threads[thread][name] != ""
 }
 
-// Function to add file to board cache and queue.
-func (b *Board) addFile(thread string, post int, name, path string) error {
-	b.cache.RLock()
-	b.cache.threads[thread][name] = path
-	b.Queue = append(b.Queue, FileInfo{name, path, thread, strconv.Itoa(post)})
+// Function to add file to board cache.
+func (b *Board) addFile(thread string, file FileInfo) error {
+	b.cache.Lock()
+	defer b.cache.Unlock()
+	if _, ok := b.cache.threads[thread]; !ok {
+		return errors.New("No such thread in cache")
+	}
+	b.cache.threads[thread][file.Name] = file.Path
+	b.stateChanged()
 
 	return nil
 }
 
+// Function to add new files to queue. Files of weighted boards wait to be mixed according to their weights.
+func (b *Board) enqueue(files []FileInfo) {
+	files = b.reposts.filter(files, time.Now())
+	if source, ok := b.source.(weightedSource); ok && len(files) != 0 {
+		b.push(b.addPending(source, files, time.Now()))
+		b.stateChanged()
+		b.release()
+		return
+	}
+	b.push(files)
+}
+
+// Function to push files to the end of queue.
+func (b *Board) push(files []FileInfo) {
+	evicted := b.Queue.Push(files...)
+	if len(files) != 0 || len(evicted) != 0 {
+		b.stateChanged()
+	}
+	b.publishFiles(FileAdded, files)
+	if len(evicted) != 0 {
+		log.Println("Evicted ", len(evicted), " old files from queue")
+		b.publishFiles(FileRemoved, evicted)
+	}
+}
+
+// Function to set time during which file with the same hash found in any thread is treated as repost and
+// not added to queue. Zero disables detection.
+func (b *Board) SetRepostWindow(window time.Duration) {
+	b.reposts.setWindow(window)
+}
+
+// Function returns how many times file with indicated hash was reposted within repost window.
+func (b *Board) Reposts(hash string) int {
+	return b.reposts.count(hash)
+}
+
+// Function to remove file from queue by position, e.g. if it's not available anymore.
+func (b *Board) RemoveFile(position int) (FileInfo, bool) {
+	file, ok := b.Queue.Remove(position)
+	if ok {
+		b.stateChanged()
+		b.publishFiles(FileRemoved, []FileInfo{file})
+	}
+	return file, ok
+}
+
+// Function to set maximum number of files in queue and maximum age of file in queue. Zero means no limit.
+func (b *Board) SetQueueLimits(capacity int, maxAge time.Duration) {
+	evicted := b.Queue.SetLimits(capacity, maxAge)
+	if len(evicted) != 0 {
+		b.stateChanged()
+		log.Println("Evicted ", len(evicted), " old files from queue")
+		b.publishFiles(FileRemoved, evicted)
+	}
+}
+
 // Function to check board for a new WEBM threads and save them to cache.
-func (b *Board) scan4Treads() error {
-	var page boardMainPage
-	log.Println("Inititated scan 0 page for a new WEBM threads")
-	response, err := b.getUrl(b.config.JSONUrl)
-	if err != nil {
-		return err
+func (b *Board) scan4Treads(ctx context.Context) error {
+	b.refresh.Lock()
+	timeout := b.refresh.timeout
+	b.refresh.Unlock()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	threads, err := b.source.Threads(ctx)
+	if err == ErrNotModified {
+		return nil
 	}
-	err = json.Unmarshal(response, &page)
 	if err != nil {
 		return err
 	}
-	for _, thread := range page.Threads {
-		for _, file := range thread.Posts[0].Files {
-			if b.filenameWebmRegexp.MatchString(file.Path) && b.threadWebmRegexp.MatchString(thread.Posts[0].Comment) {
-				if !b.isThread(thread.Thread_num) {
-					log.Println("Found new WEBM thread ", thread.Thread_num)
-					b.addThread(thread.Thread_num)
-				}
-			}
+	for _, thread := range threads {
+		if !b.isThread(thread) {
+			log.Println("Found new WEBM thread ", thread)
+			b.addThread(thread)
 		}
-
 	}
 
 	return nil
 }
 
 // Function to check all threads from cache if they have new webm files.
-func (b *Board) updateThreadsPosts() error {
-	var page boardPage
-	for thread_num, _ := range b.getThreadsMap() {
-		response, err := b.getUrl(b.config.DownloadURL + "res/" + thread_num + ".json")
+func (b *Board) updateThreadsPosts(ctx context.Context) error {
+	return b.updateThreads(ctx, b.getThreadsList())
+}
+
+// Function to check indicated threads for a new webm files and plan their next polls. Threads are
+// fetched in parallel, results are merged into cache one by one in order of threads. Threads of host blocked
+// by Cloudflare challenge are paused, BlockedError is returned then.
+func (b *Board) updateThreads(ctx context.Context, threads []string) error {
+	var newFiles []FileInfo
+	var blocked error
+	results := b.fetchThreads(ctx, threads)
+	for _, result := range results {
+		thread_num, files, err := result.thread, result.files, result.err
+		if !result.done {
+			continue
+		}
+		if err != nil && ctx.Err() != nil {
+			// Board is stopped, don't treat aborted requests as dead threads
+			continue
+		}
+		if blockedErr, ok := err.(*BlockedError); ok {
+			b.threadPolled(thread_num, err)
+			b.hostBlocked(ctx, thread_num, blockedErr)
+			blocked = err
+			continue
+		}
+		if err == ErrNotModified {
+			b.threadAlive(thread_num)
+			b.threadPolled(thread_num, nil)
+			b.polls.polled(thread_num, 0, time.Now())
+			continue
+		}
+		if err == ErrNotFound {
+			b.threadNotFound(thread_num)
+			continue
+		}
 		if err != nil {
-			b.deleteThread(thread_num)
-		} else {
-			err = json.Unmarshal(response, &page)
-			if err != nil {
-				return err
+			log.Println("Error on updating thread ", thread_num, ", will retry: ", err)
+			b.threadPolled(thread_num, err)
+			b.polls.retry(thread_num, time.Now())
+			continue
+		}
+		b.threadAlive(thread_num)
+		b.threadPolled(thread_num, nil)
+		threadFiles := len(newFiles)
+		for _, file := range files {
+			if file.Type == "" {
+				file.Type = MediaType(file.Name)
 			}
-			for _, files := range page.Threads[0].Posts {
-				for _, file := range files.Files {
-					if b.filenameWebmRegexp.MatchString(file.Name) && !b.isFile(thread_num, file.Name) {
-
-						log.Println("Adding new file ", file.Name, " from thread ", thread_num, " to queue")
-						err := b.addFile(thread_num, files.Num, file.Name, file.Path)
-						if err != nil {
-							log.Println("Error on adding file ", file.Name, " ", file.Path, " Text: ", err)
-						}
-					}
+			if !b.isFile(thread_num, file.Name) {
+				log.Println("Adding new file ", file.Name, " from thread ", thread_num, " to queue")
+				err := b.addFile(thread_num, file)
+				if err != nil {
+					log.Println("Error on adding file ", file.Name, " ", file.Path, " Text: ", err)
+				} else {
+					newFiles = append(newFiles, file)
 				}
 			}
-
 		}
+		b.polls.polled(thread_num, len(newFiles)-threadFiles, time.Now())
 	}
+	b.enqueue(newFiles)
 
-	return nil
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return blocked
 }
 
-// Function to refresh board state and update cache. Exported in case want do it manually.
-func (b *Board) Refresh() error {
-	err := b.scan4Treads()
-	if err != nil {
-		return err
+// Function to refresh board state and update cache. Exported in case want do it manually. Already known
+// threads are updated even if scan for a new threads failed.
+func (b *Board) Refresh(ctx context.Context) error {
+	scanErr := b.scan4Treads(ctx)
+	if scanErr != nil {
+		log.Println("Error on scanning for a new threads: ", scanErr)
 	}
 
-	err = b.updateThreadsPosts()
+	err := b.updateThreadsPosts(ctx)
+	b.release()
 	if err != nil {
 		return err
 	}
-	return nil
+	return scanErr
+}
+
+// Function returns health of board watcher.
+func (b *Board) Status() Status {
+	b.status.Lock()
+	status := b.status.Status
+	b.status.Unlock()
+	status.BlockedSince = b.blockedSince()
+	status.Blocked = !status.BlockedSince.IsZero()
+	return status
 }
 
-// Function to continiously watch updates.
-func (b *Board) watcher() {
+// Function to save result of refresh and return delay before next one. After failures delay grows
+// exponentially with random jitter, so board is not hammered while it's down.
+func (b *Board) refreshed(err error) time.Duration {
+	b.status.Lock()
+	defer b.status.Unlock()
+	if err == nil {
+		b.status.Failures = 0
+		b.status.LastSuccess = time.Now()
+		return time.Duration(120+rand.Int31n(240)) * time.Second
+	}
+
+	b.status.Failures++
+	b.status.LastError = err
+	b.status.LastErrorTime = time.Now()
+
+	delay := retryDelay(b.status.Failures)
+	log.Println("Error refreshing board(", b.status.Failures, " times in a row), retry in ", delay, ": ", err)
+	return delay
+}
+
+// Function to save result of thread poll in status. Failed thread polls are retried by their own schedule
+// and don't delay scans for a new threads.
+func (b *Board) threadPolled(thread string, err error) {
+	b.status.Lock()
+	defer b.status.Unlock()
+	if err == nil {
+		b.status.PollFailures = 0
+		return
+	}
+	b.status.PollFailures++
+	b.status.LastError = errors.New("Error on updating thread " + thread + ": " + err.Error())
+	b.status.LastErrorTime = time.Now()
+}
+
+// Function to continiously watch updates until context is canceled. Board is scanned for a new threads
+// every 2-6 minutes(or later after failures), known threads are polled by their own schedule. Threads of
+// hosts blocked by Cloudflare challenge are paused until host is unblocked, other threads are polled as
+// usual.
+func (b *Board) watcher(ctx context.Context) {
+	defer b.lifecycle.wait.Done()
+	nextScan := time.Now()
 	for {
-		err := b.Refresh()
-		if err != nil {
-			log.Fatalln("Error refreshing board: ", err)
+		if !time.Now().Before(nextScan) {
+			err := b.scan4Treads(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if blocked, ok := err.(*BlockedError); ok {
+				b.hostBlocked(ctx, "", blocked)
+			}
+			nextScan = time.Now().Add(b.refreshed(err))
 		}
-		time.Sleep(time.Duration(120+rand.Int31n(240)) * time.Second)
+
+		threads := b.activeThreads(b.getThreadsList(), time.Now())
+		due := b.polls.due(threads, time.Now())
+		if len(due) != 0 {
+			err := b.updateThreads(ctx, due)
+			if ctx.Err() != nil {
+				return
+			}
+			err = b.saveChangedState()
+			if err != nil {
+				log.Println("Error on saving board state: ", err)
+			}
+			threads = b.activeThreads(b.getThreadsList(), time.Now())
+		}
+
+		b.release()
+		next := b.polls.nextPoll(threads, time.Now())
+		if release := b.nextRelease(); !release.IsZero() && release.Before(next) {
+			next = release
+		}
+		if check := b.nextBlockedCheck(); !check.IsZero() && check.Before(next) {
+			next = check
+		}
+		if nextScan.Before(next) {
+			next = nextScan
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.blocked.wake:
+			// Host is unblocked, e.g. fresh credentials are set, so scan is not delayed anymore
+			nextScan = time.Now()
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// Starts automatic board cache updates. Updates are stopped when context is canceled or Stop is called.
+func (b *Board) Start(ctx context.Context) error {
+	b.lifecycle.Lock()
+	defer b.lifecycle.Unlock()
+	if b.lifecycle.cancel != nil {
+		return errors.New("Board is already started")
+	}
+	ctx, b.lifecycle.cancel = context.WithCancel(ctx)
+	b.lifecycle.wait.Add(1)
+	go b.watcher(ctx)
+	return nil
+}
+
+// Function to stop automatic updates, wait until current refresh is aborted and save board state.
+func (b *Board) Stop() error {
+	b.lifecycle.Lock()
+	defer b.lifecycle.Unlock()
+	if b.lifecycle.cancel == nil {
+		return nil
 	}
+	b.lifecycle.cancel()
+	b.lifecycle.wait.Wait()
+	b.lifecycle.cancel = nil
+	return b.SaveState()
 }
 
-// Starts automatic board cache updates every 2-6 minutes.
+// Starts automatic board cache updates, which could be stopped only with Stop.
 func (b *Board) AutoWatcher() {
-	go b.watcher()
+	err := b.Start(context.Background())
+	if err != nil {
+		log.Println("Error on starting board watcher: ", err)
+	}
 }
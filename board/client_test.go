@@ -0,0 +1,197 @@
+package board
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Type to describe response of test server
+type testResponse struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+// Function starts server which responds with indicated response and saves headers of last request.
+func newTestServer(response *testResponse, lastRequest *http.Header, lock *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		*lastRequest = req.Header.Clone()
+		for name, value := range response.header {
+			resp.Header().Set(name, value)
+		}
+		resp.WriteHeader(response.status)
+		resp.Write([]byte(response.body))
+	}))
+}
+
+func TestClientConditionalRequests(t *testing.T) {
+	var lock sync.Mutex
+	var request http.Header
+	response := &testResponse{200, map[string]string{"ETag": `"v1"`, "Last-Modified": "Mon, 02 Jan 2006 15:04:05 GMT"}, `{"value": 1}`}
+	server := newTestServer(response, &request, &lock)
+	defer server.Close()
+	client := NewClient("test")
+	var v struct{ Value int }
+
+	err := client.getParsed(context.Background(), server.URL, json.Unmarshal, &v)
+	if err != nil || v.Value != 1 {
+		t.Fatalf("Unexpected result of first request %v, %v", v, err)
+	}
+	if request.Get("If-None-Match") != "" || request.Get("If-Modified-Since") != "" {
+		t.Errorf("First request should not be conditional, got %v", request)
+	}
+	if request.Get("User-Agent") != "test" {
+		t.Errorf("Unexpected User-Agent %s", request.Get("User-Agent"))
+	}
+
+	lock.Lock()
+	response.status, response.body = http.StatusNotModified, ""
+	lock.Unlock()
+	err = client.getParsed(context.Background(), server.URL, json.Unmarshal, &v)
+	if err != ErrNotModified {
+		t.Errorf("Expected ErrNotModified, got %v", err)
+	}
+	if request.Get("If-None-Match") != `"v1"` || request.Get("If-Modified-Since") != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Errorf("Request should be conditional, got %v", request)
+	}
+
+	// Validators of broken response are not remembered
+	lock.Lock()
+	response.status, response.body = 200, "{broken"
+	response.header = map[string]string{"ETag": `"v2"`}
+	lock.Unlock()
+	if err = client.getParsed(context.Background(), server.URL, json.Unmarshal, &v); err == nil {
+		t.Error("Broken response should not be parsed")
+	}
+	if _, ok := client.validator(server.URL); ok {
+		t.Error("Validators of broken response should be forgotten")
+	}
+	lock.Lock()
+	response.body = `{"value": 2}`
+	lock.Unlock()
+	err = client.getParsed(context.Background(), server.URL, json.Unmarshal, &v)
+	if err != nil || v.Value != 2 {
+		t.Fatalf("Unexpected result of request after broken one %v, %v", v, err)
+	}
+	if request.Get("If-None-Match") != "" {
+		t.Errorf("Request after broken response should not be conditional, got %v", request)
+	}
+}
+
+func TestClientResponseErrors(t *testing.T) {
+	challenge := map[string]string{"Server": "cloudflare", "Cf-Ray": "1", "Content-Type": "text/html"}
+	tests := []struct {
+		name     string
+		response testResponse
+		err      error
+		blocked  bool
+	}{
+		{"ok", testResponse{200, nil, "{}"}, nil, false},
+		{"not found", testResponse{404, nil, ""}, ErrNotFound, false},
+		{"gone", testResponse{410, nil, ""}, ErrNotFound, false},
+		{"server error", testResponse{500, nil, ""}, nil, false},
+		{"nginx unavailable", testResponse{503, map[string]string{"Server": "nginx", "Content-Type": "text/html"}, "<html></html>"}, nil, false},
+		{"challenge", testResponse{403, challenge, "<html></html>"}, nil, true},
+		{"challenge unavailable", testResponse{503, challenge, "<!DOCTYPE html>"}, nil, true},
+		{"challenge with ok status", testResponse{200, map[string]string{"Cf-Ray": "1"}, "<html></html>"}, nil, true},
+		{"mitigated", testResponse{403, map[string]string{"Cf-Mitigated": "challenge"}, ""}, nil, true},
+	}
+	for _, test := range tests {
+		var lock sync.Mutex
+		var request http.Header
+		server := newTestServer(&test.response, &request, &lock)
+		client := NewClient("test")
+		_, _, err := client.getUrl(context.Background(), server.URL)
+		server.Close()
+
+		blocked, isBlocked := err.(*BlockedError)
+		if isBlocked != test.blocked {
+			t.Errorf("%s: expected blocked %v, got %v", test.name, test.blocked, err)
+			continue
+		}
+		if isBlocked {
+			if !strings.Contains(server.URL, blocked.Host) || client.Blocked().IsZero() {
+				t.Errorf("%s: host %s should be blocked", test.name, blocked.Host)
+			}
+			continue
+		}
+		if test.err != nil && err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+		if test.err == nil && (err == nil) != (test.response.status == 200) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func TestClientBlockedHosts(t *testing.T) {
+	var lock sync.Mutex
+	var request http.Header
+	response := &testResponse{403, map[string]string{"Cf-Mitigated": "challenge"}, ""}
+	blockedServer := newTestServer(response, &request, &lock)
+	defer blockedServer.Close()
+	okServer := newTestServer(&testResponse{200, nil, "{}"}, &request, &lock)
+	defer okServer.Close()
+	// The same server is another host if it's requested by name
+	okURL := strings.Replace(okServer.URL, "127.0.0.1", "localhost", 1)
+	client := NewClient("test")
+
+	_, _, err := client.getUrl(context.Background(), blockedServer.URL)
+	blocked, ok := err.(*BlockedError)
+	if !ok {
+		t.Fatalf("Expected BlockedError, got %v", err)
+	}
+
+	// Response from other host doesn't unblock
+	if _, _, err = client.getUrl(context.Background(), okURL); err != nil {
+		t.Fatal("Request to other host failed: ", err)
+	}
+	select {
+	case <-blocked.Unblocked():
+		t.Fatal("Host should not be unblocked by response from other host")
+	default:
+	}
+
+	// Fresh credentials for other host don't unblock too
+	client.SetCookie(okURL, "cf_clearance", "value")
+	select {
+	case <-blocked.Unblocked():
+		t.Fatal("Host should not be unblocked by cookie of other host")
+	default:
+	}
+
+	client.SetCookie(blockedServer.URL, "cf_clearance", "value")
+	select {
+	case <-blocked.Unblocked():
+	default:
+		t.Fatal("Host should be unblocked by fresh cookie")
+	}
+	if !client.Blocked().IsZero() {
+		t.Error("Client should not be blocked")
+	}
+
+	// Challenge which is gone unblocks host too
+	_, _, err = client.getUrl(context.Background(), blockedServer.URL)
+	blocked, ok = err.(*BlockedError)
+	if !ok {
+		t.Fatalf("Expected BlockedError, got %v", err)
+	}
+	lock.Lock()
+	*response = testResponse{200, nil, "{}"}
+	lock.Unlock()
+	if _, _, err = client.getUrl(context.Background(), blockedServer.URL); err != nil {
+		t.Fatal("Request after challenge failed: ", err)
+	}
+	select {
+	case <-blocked.Unblocked():
+	default:
+		t.Error("Host should be unblocked when challenge is gone")
+	}
+}
@@ -0,0 +1,59 @@
+package board
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Default time after which thread not found on board is forgotten with it's files
+const DefaultDeadThreadGrace = 30 * time.Minute
+
+// Type to keep time when threads were not found on board for the first time
+type deadThreads struct {
+	sync.Mutex
+	grace   time.Duration
+	threads map[string]time.Time
+}
+
+// Function to set time after which thread not found on board is forgotten.
+func (b *Board) SetDeadThreadGrace(grace time.Duration) {
+	b.dead.Lock()
+	defer b.dead.Unlock()
+	b.dead.grace = grace
+}
+
+// Function to handle thread which is not found on board. Thread is still polled during grace period, in
+// case it's back, and removed from cache after.
+func (b *Board) threadNotFound(thread string) {
+	now := time.Now()
+	b.dead.Lock()
+	if b.dead.threads == nil {
+		b.dead.threads = make(map[string]time.Time)
+	}
+	since, ok := b.dead.threads[thread]
+	if !ok {
+		since = now
+		b.dead.threads[thread] = now
+	}
+	grace := b.dead.grace
+	b.dead.Unlock()
+
+	if now.Sub(since) >= grace {
+		log.Println("Thread ", thread, " is not found since ", since.Format(time.RFC3339), ", removing it from cache")
+		b.deleteThread(thread)
+		return
+	}
+	log.Println("Thread ", thread, " is not found, it will be removed from cache if it's not back in ", since.Add(grace).Sub(now).Round(time.Second))
+	b.polls.polled(thread, 0, now)
+}
+
+// Function to mark thread as alive, e.g. if it was not found because of board glitch.
+func (b *Board) threadAlive(thread string) {
+	b.dead.Lock()
+	defer b.dead.Unlock()
+	if _, ok := b.dead.threads[thread]; ok {
+		log.Println("Thread ", thread, " is back")
+		delete(b.dead.threads, thread)
+	}
+}
@@ -0,0 +1,142 @@
+package board
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Type to represent local directory tree as a source of video files. Every directory with videos
+// is a thread, so new files copied by rsync or anything else appear in queue on next refresh.
+type Directory struct {
+	mediaFilter
+
+	// Struct to save the configuration
+	config struct {
+		Root string
+	}
+
+	// Transport to read files of Root
+	local *localFiles
+}
+
+// Type to read local files by file:// links, files outside of root are not served.
+type localFiles struct {
+	root  string
+	files http.RoundTripper
+}
+
+// Function to serve local file requested by file:// link.
+func (l *localFiles) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "file" {
+		return nil, errors.New("Only file:// links are served from directory")
+	}
+	rel, err := filepath.Rel(l.root, filepath.FromSlash(req.URL.Path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, errors.New("File is outside of directory: " + req.URL.Path)
+	}
+	local := req.Clone(req.Context())
+	local.URL.Path = "/" + filepath.ToSlash(rel)
+	return l.files.RoundTrip(local)
+}
+
+// Generates new directory source which watches all subdirectories of Root.
+func NewDirectory(Root string) (*Directory, error) {
+	root, err := filepath.Abs(Root)
+	if err != nil {
+		return nil, err
+	}
+	source := new(Directory)
+	source.config.Root = root
+	source.local = &localFiles{root, http.NewFileTransport(http.Dir(root))}
+
+	source.SetExtensions(DefaultExtensions())
+
+	return source, nil
+}
+
+// Function returns true if directory entry should be skipped, e.g. hidden or temporary files of rsync.
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// Function to get all directories which have video files.
+func (d *Directory) Threads(ctx context.Context) ([]string, error) {
+	var threads []string
+	seen := make(map[string]bool)
+	log.Println("Inititated scan of ", d.config.Root, " for a new directories with video")
+	err := filepath.Walk(d.config.Root, func(name string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Println("Error on walking ", name, ": ", err)
+			return nil
+		}
+		if name != d.config.Root && isHidden(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !d.isMedia(info.Name()) {
+			return nil
+		}
+		thread, err := filepath.Rel(d.config.Root, filepath.Dir(name))
+		if err != nil {
+			return err
+		}
+		thread = filepath.ToSlash(thread)
+		if !seen[thread] {
+			seen[thread] = true
+			threads = append(threads, thread)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return threads, nil
+}
+
+// Function to get all video files from directory.
+func (d *Directory) Files(ctx context.Context, thread string) ([]FileInfo, error) {
+	var files []FileInfo
+	entries, err := ioutil.ReadDir(filepath.Join(d.config.Root, filepath.FromSlash(thread)))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || isHidden(entry.Name()) || !d.isMedia(entry.Name()) {
+			continue
+		}
+		files = append(files, FileInfo{Name: entry.Name(), Path: path.Join(thread, entry.Name()), Thread: thread, Post: entry.Name()})
+	}
+	return files, nil
+}
+
+// Function returns file:// link to video, it could be downloaded only with transport of directory.
+func (d *Directory) FileURL(file FileInfo) string {
+	link := url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(d.config.Root, filepath.FromSlash(file.Path)))}
+	return link.String()
+}
+
+// Function returns transport which reads files of directory.
+func (d *Directory) transport(file FileInfo) http.RoundTripper {
+	return d.local
+}
+
+// Function returns empty string, local files have no posts.
+func (d *Directory) PostURL(file FileInfo) string {
+	return ""
+}
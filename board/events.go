@@ -0,0 +1,98 @@
+package board
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Size of subscriber channel buffer. If subscriber is too slow and buffer is full, events are dropped.
+const subscriberBuffer = 256
+
+// Type of board event
+type EventType int
+
+const (
+	// New thread found and added to cache
+	ThreadDiscovered EventType = iota
+	// Thread removed from cache
+	ThreadDied
+	// File added to queue
+	FileAdded
+	// File removed or evicted from queue
+	FileRemoved
+)
+
+// Function returns human-readable name of event type.
+func (t EventType) String() string {
+	switch t {
+	case ThreadDiscovered:
+		return "ThreadDiscovered"
+	case ThreadDied:
+		return "ThreadDied"
+	case FileAdded:
+		return "FileAdded"
+	case FileRemoved:
+		return "FileRemoved"
+	}
+	return "Unknown"
+}
+
+// Type represents change of board state. Thread is a thread name in board cache(as returned by source)
+// and filled only for thread events, File is filled only for file events.
+type Event struct {
+	Type   EventType
+	Thread string
+	File   FileInfo
+	Time   time.Time
+}
+
+// Type to keep channels of subscribers with lock
+type subscribers struct {
+	sync.Mutex
+	channels map[<-chan Event]chan Event
+}
+
+// Function returns channel with board events. Channel should be read without long delays, otherwise
+// events are dropped. Call Unsubscribe to stop receiving events.
+func (b *Board) Subscribe() <-chan Event {
+	channel := make(chan Event, subscriberBuffer)
+	b.subscribers.Lock()
+	defer b.subscribers.Unlock()
+	if b.subscribers.channels == nil {
+		b.subscribers.channels = make(map[<-chan Event]chan Event)
+	}
+	b.subscribers.channels[channel] = channel
+	return channel
+}
+
+// Function to stop sending events to channel returned by Subscribe. Channel is closed.
+func (b *Board) Unsubscribe(channel <-chan Event) {
+	b.subscribers.Lock()
+	defer b.subscribers.Unlock()
+	if subscriber, ok := b.subscribers.channels[channel]; ok {
+		delete(b.subscribers.channels, channel)
+		close(subscriber)
+	}
+}
+
+// Function to send event to all subscribers without blocking.
+func (b *Board) publish(event Event) {
+	event.Time = time.Now()
+	b.subscribers.Lock()
+	defer b.subscribers.Unlock()
+	for _, subscriber := range b.subscribers.channels {
+		select {
+		case subscriber <- event:
+		default:
+			log.Println("Subscriber is too slow, dropping event ", event.Type)
+		}
+	}
+}
+
+// Function to publish events about files.
+func (b *Board) publishFiles(eventType EventType, files []FileInfo) {
+	for _, file := range files {
+		b.publish(Event{Type: eventType, File: file})
+	}
+}
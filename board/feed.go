@@ -0,0 +1,230 @@
+package board
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"log"
+	"net/url"
+	"path"
+	"sync"
+)
+
+// Type to parse both RSS and Atom feeds. Only one of Channel or Entries is filled depending on format.
+type feedDocument struct {
+	XMLName xml.Name
+
+	// RSS
+	Channel struct {
+		Items []struct {
+			GUID       string `xml:"guid"`
+			Link       string `xml:"link"`
+			Enclosures []struct {
+				URL  string `xml:"url,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"enclosure"`
+		} `xml:"item"`
+	} `xml:"channel"`
+
+	// Atom
+	Entries []struct {
+		ID    string `xml:"id"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+// Type to represent video enclosure
+type feedVideo struct {
+	URL  string
+	Type string
+}
+
+// Type to represent single feed item with video enclosures
+type feedItem struct {
+	ID     string
+	Videos []feedVideo
+}
+
+// Function returns enclosures of all feed items, both for RSS and Atom, which are accepted by filter.
+func (d *feedDocument) items(accept func(video feedVideo) bool) []feedItem {
+	var items []feedItem
+	for _, rssItem := range d.Channel.Items {
+		item := feedItem{ID: rssItem.GUID}
+		if item.ID == "" {
+			item.ID = rssItem.Link
+		}
+		for _, enclosure := range rssItem.Enclosures {
+			video := feedVideo{enclosure.URL, enclosure.Type}
+			if video.URL != "" && accept(video) {
+				item.Videos = append(item.Videos, video)
+			}
+		}
+		items = append(items, item)
+	}
+	for _, entry := range d.Entries {
+		item := feedItem{ID: entry.ID}
+		for _, link := range entry.Links {
+			video := feedVideo{link.Href, link.Type}
+			if link.Rel == "enclosure" && video.URL != "" && accept(video) {
+				item.Videos = append(item.Videos, video)
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// Type to represent set of RSS/Atom feeds as a source of video files. Every feed item with video
+// enclosures is a thread.
+type Feed struct {
+	mediaFilter
+	client *Client
+
+	// Struct to save the configuration
+	config struct {
+		Feeds []string
+	}
+
+	// Items with videos from last poll of every feed, mapped by thread
+	items struct {
+		sync.Mutex
+		feeds map[string]map[string]feedItem
+	}
+}
+
+// Generates new source which polls indicated RSS or Atom feeds.
+func NewFeed(client *Client, Feeds ...string) *Feed {
+	source := new(Feed)
+	source.client = client
+	source.config.Feeds = Feeds
+	source.SetExtensions(DefaultExtensions())
+	source.items.feeds = make(map[string]map[string]feedItem)
+	return source
+}
+
+// Function returns thread name for feed item. Item ID could be any URL, so hash is used to get
+// short name which is safe to use as directory name.
+func feedThread(feedURL, itemID string) string {
+	sum := sha1.Sum([]byte(feedURL + "\n" + itemID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Function returns true if link is an absolute http or https URL. Feed is a remote content, so other
+// links, e.g. file://, are never followed.
+func isWebURL(link string) bool {
+	parsed, err := url.Parse(link)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// Function to check if enclosure is a video by it's type or, if type is not indicated, by extension.
+// Only http and https enclosures are accepted.
+func (f *Feed) isVideo(video feedVideo) bool {
+	if !isWebURL(video.URL) {
+		return false
+	}
+	if video.Type != "" {
+		return f.isMediaType(video.Type)
+	}
+	link, err := url.Parse(video.URL)
+	return err == nil && f.isMedia(link.Path)
+}
+
+// Function to poll single feed and return items with videos mapped by thread.
+func (f *Feed) poll(ctx context.Context, feedURL string) (map[string]feedItem, error) {
+	var document feedDocument
+	err := f.client.getParsed(ctx, feedURL, xml.Unmarshal, &document)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]feedItem)
+	for _, item := range document.items(f.isVideo) {
+		if len(item.Videos) == 0 {
+			continue
+		}
+		if item.ID == "" {
+			item.ID = item.Videos[0].URL
+		}
+		items[feedThread(feedURL, item.ID)] = item
+	}
+	return items, nil
+}
+
+// Function to poll all feeds and get items with videos. If feed is unavailable, items from previous poll are
+// kept. Error is returned only if all feeds are failed.
+func (f *Feed) Threads(ctx context.Context) ([]string, error) {
+	var threads []string
+	var lastErr error
+	failed := 0
+	for _, feedURL := range f.config.Feeds {
+		log.Println("Inititated poll of feed ", feedURL)
+		items, err := f.poll(ctx, feedURL)
+		if err == ErrNotModified {
+			continue
+		}
+		if err != nil {
+			log.Println("Error on polling feed ", feedURL, ": ", err)
+			lastErr = err
+			failed++
+		} else {
+			f.items.Lock()
+			f.items.feeds[feedURL] = items
+			f.items.Unlock()
+		}
+	}
+	if failed != 0 && failed == len(f.config.Feeds) {
+		return nil, lastErr
+	}
+
+	f.items.Lock()
+	defer f.items.Unlock()
+	for _, items := range f.items.feeds {
+		for thread := range items {
+			threads = append(threads, thread)
+		}
+	}
+	return threads, nil
+}
+
+// Function returns videos of feed item.
+func (f *Feed) Files(ctx context.Context, thread string) ([]FileInfo, error) {
+	var files []FileInfo
+	f.items.Lock()
+	defer f.items.Unlock()
+	for _, items := range f.items.feeds {
+		item, ok := items[thread]
+		if !ok {
+			continue
+		}
+		for _, video := range item.Videos {
+			if !isWebURL(video.URL) {
+				continue
+			}
+			name := thread + path.Ext(video.URL)
+			if link, err := url.Parse(video.URL); err == nil && path.Base(link.Path) != "/" && path.Base(link.Path) != "." {
+				name = path.Base(link.Path)
+			}
+			files = append(files, FileInfo{Name: name, Path: video.URL, Thread: thread, Post: item.ID, Type: video.Type})
+		}
+		return files, nil
+	}
+	// Item is not in feed anymore
+	return nil, ErrNotFound
+}
+
+// Function returns link to enclosure. Path of feed file is a link itself.
+func (f *Feed) FileURL(file FileInfo) string {
+	return file.Path
+}
+
+// Function returns link to feed item if it's ID is a link, otherwise empty string.
+func (f *Feed) PostURL(file FileInfo) string {
+	if !isWebURL(file.Post) {
+		return ""
+	}
+	return file.Post
+}
@@ -0,0 +1,175 @@
+package board
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+const testRSS = `<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+  <item>
+    <guid>https://example.org/posts/1</guid>
+    <enclosure url="https://media.example.org/1.webm" type="video/webm"/>
+  </item>
+  <item>
+    <link>https://example.org/posts/2</link>
+    <enclosure url="https://media.example.org/2.mp4"/>
+    <enclosure url="https://media.example.org/2.jpg" type="image/jpeg"/>
+  </item>
+  <item>
+    <guid>https://example.org/posts/4</guid>
+    <enclosure url="https://media.example.org/4.mp4" type="video/mp4; codecs=&quot;avc1.42E01E, mp4a.40.2&quot;"/>
+  </item>
+  <item>
+    <guid>local</guid>
+    <enclosure url="file:///etc/hostname" type="video/mp4"/>
+  </item>
+  <item>
+    <guid>picture</guid>
+    <enclosure url="https://media.example.org/3.png" type="image/png"/>
+  </item>
+</channel>
+</rss>`
+
+const testAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <id>tag:example.org,2016:1</id>
+    <link rel="alternate" href="https://example.org/entries/1"/>
+    <link rel="enclosure" href="http://media.example.org/a.webm" type="video/webm"/>
+  </entry>
+  <entry>
+    <id>tag:example.org,2016:2</id>
+    <link rel="enclosure" href="file:///etc/shadow" type="video/webm"/>
+  </entry>
+</feed>`
+
+// Function starts server with test feeds.
+func newTestFeedServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/rss":
+			resp.Write([]byte(testRSS))
+		case "/atom":
+			resp.Write([]byte(testAtom))
+		default:
+			http.NotFound(resp, req)
+		}
+	}))
+}
+
+// Function returns all files of all feed threads sorted by path.
+func feedFiles(t *testing.T, feed *Feed) []FileInfo {
+	threads, err := feed.Threads(context.Background())
+	if err != nil {
+		t.Fatal("Threads failed: ", err)
+	}
+	var files []FileInfo
+	for _, thread := range threads {
+		threadFiles, err := feed.Files(context.Background(), thread)
+		if err != nil {
+			t.Fatal("Files of ", thread, " failed: ", err)
+		}
+		files = append(files, threadFiles...)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+func TestFeedRSS(t *testing.T) {
+	server := newTestFeedServer()
+	defer server.Close()
+	feed := NewFeed(NewClient("test"), server.URL+"/rss")
+
+	files := feedFiles(t, feed)
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %+v", files)
+	}
+	if files[0].Name != "1.webm" || files[0].Type != "video/webm" || files[0].Post != "https://example.org/posts/1" {
+		t.Errorf("Unexpected first file %+v", files[0])
+	}
+	if files[1].Name != "2.mp4" || files[1].Post != "https://example.org/posts/2" {
+		t.Errorf("Unexpected second file %+v", files[1])
+	}
+	if files[2].Name != "4.mp4" {
+		t.Errorf("Enclosure with codecs in type should be accepted, got %+v", files[2])
+	}
+	if url := feed.FileURL(files[0]); url != "https://media.example.org/1.webm" {
+		t.Errorf("Unexpected file URL %s", url)
+	}
+	if url := feed.PostURL(files[1]); url != "https://example.org/posts/2" {
+		t.Errorf("Unexpected post URL %s", url)
+	}
+}
+
+func TestFeedAtom(t *testing.T) {
+	server := newTestFeedServer()
+	defer server.Close()
+	feed := NewFeed(NewClient("test"), server.URL+"/atom")
+
+	files := feedFiles(t, feed)
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 file, got %+v", files)
+	}
+	if files[0].Path != "http://media.example.org/a.webm" || files[0].Post != "tag:example.org,2016:1" {
+		t.Errorf("Unexpected file %+v", files[0])
+	}
+	if url := feed.PostURL(files[0]); url != "" {
+		t.Errorf("Post URL of tag ID should be empty, got %s", url)
+	}
+}
+
+func TestFeedRejectsLocalEnclosures(t *testing.T) {
+	server := newTestFeedServer()
+	defer server.Close()
+	feed := NewFeed(NewClient("test"), server.URL+"/rss", server.URL+"/atom")
+
+	for _, file := range feedFiles(t, feed) {
+		if !isWebURL(file.Path) {
+			t.Errorf("Local enclosure accepted: %+v", file)
+		}
+	}
+
+	// Files should not return local links even if they got into items somehow
+	feed.items.Lock()
+	feed.items.feeds["injected"] = map[string]feedItem{
+		"thread": {ID: "item", Videos: []feedVideo{{URL: "file:///etc/shadow", Type: "video/webm"}}},
+	}
+	feed.items.Unlock()
+	files, err := feed.Files(context.Background(), "thread")
+	if err != nil {
+		t.Fatal("Files failed: ", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Local enclosure returned by Files: %+v", files)
+	}
+}
+
+func TestFeedKeepsItemsOnError(t *testing.T) {
+	server := newTestFeedServer()
+	other := newTestFeedServer()
+	feed := NewFeed(NewClient("test"), server.URL+"/rss", other.URL+"/atom")
+	threads, err := feed.Threads(context.Background())
+	if err != nil || len(threads) != 4 {
+		t.Fatalf("Expected 4 threads, got %v, %v", threads, err)
+	}
+
+	other.Close()
+	threads, err = feed.Threads(context.Background())
+	if err != nil || len(threads) != 4 {
+		t.Errorf("Items of unavailable feed should be kept, got %v, %v", threads, err)
+	}
+
+	server.Close()
+	if _, err = feed.Threads(context.Background()); err == nil {
+		t.Error("Error should be returned when all feeds are unavailable")
+	}
+	files, err := feed.Files(context.Background(), threads[0])
+	if err != nil || len(files) == 0 {
+		t.Errorf("Files of unavailable feed should be kept, got %v, %v", files, err)
+	}
+}
@@ -0,0 +1,140 @@
+package board
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+)
+
+// Type to parse 4chan-compatible catalog.json. Catalog is a list of pages with OP posts.
+type fourChanCatalog []struct {
+	Page    int
+	Threads []fourChanPost
+}
+
+// Type to parse 4chan-compatible thread/<no>.json
+type fourChanThread struct {
+	Posts []fourChanPost
+}
+
+// Type to parse single post of 4chan-compatible API. Post has only one file described with tim+ext.
+type fourChanPost struct {
+	No       int    `json:"no"`
+	Sub      string `json:"sub"`
+	Com      string `json:"com"`
+	Tim      int64  `json:"tim"`
+	Ext      string `json:"ext"`
+	Filename string `json:"filename"`
+	Md5      string `json:"md5"`
+	Fsize    int64  `json:"fsize"`
+	W        int    `json:"w"`
+	H        int    `json:"h"`
+	Time     int64  `json:"time"`
+	Archived int    `json:"archived"`
+}
+
+// Function returns name of post file on media host or empty string if post has no file.
+func (p fourChanPost) fileName() string {
+	if p.Tim == 0 || p.Ext == "" {
+		return ""
+	}
+	return strconv.FormatInt(p.Tim, 10) + p.Ext
+}
+
+// Type to represent board which speaks 4chan-compatible JSON API as a source of WEBM files.
+type FourChan struct {
+	threadFilter
+	mediaFilter
+	client *Client
+
+	// Struct to save the configuration
+	config struct {
+		SiteURL  string
+		APIURL   string
+		MediaURL string
+		Board    string
+	}
+}
+
+// Generates new 4chan-compatible source. SiteURL is a host with HTML pages(e.g. https://boards.4chan.org/),
+// APIURL is a host with JSON API(e.g. https://a.4cdn.org/), MediaURL is a separate host with files
+// (e.g. https://i.4cdn.org/), Board is a short name of board.
+func NewFourChan(client *Client, SiteURL, APIURL, MediaURL, Board string) *FourChan {
+	source := new(FourChan)
+	source.client = client
+	source.config.SiteURL = SiteURL
+	source.config.APIURL = APIURL
+	source.config.MediaURL = MediaURL
+	source.config.Board = Board
+
+	source.SetRules(DefaultRules())
+	source.SetExtensions(DefaultExtensions())
+
+	return source
+}
+
+// Function to get WEBM threads from board catalog.
+func (f *FourChan) Threads(ctx context.Context) ([]string, error) {
+	var catalog fourChanCatalog
+	var threads []string
+	log.Println("Inititated scan of /" + f.config.Board + "/ catalog for a new WEBM threads")
+	err := f.client.getParsed(ctx, f.config.APIURL+f.config.Board+"/catalog.json", json.Unmarshal, &catalog)
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range catalog {
+		for _, op := range page.Threads {
+			num := strconv.Itoa(op.No)
+			if f.isAllowed(num) || (f.isMedia(op.fileName()) && f.matchThread(num, op.Sub, op.Com)) {
+				threads = append(threads, num)
+			}
+		}
+	}
+
+	return f.withAllowed(threads), nil
+}
+
+// Function to get all video files from thread.
+func (f *FourChan) Files(ctx context.Context, thread string) ([]FileInfo, error) {
+	var page fourChanThread
+	var files []FileInfo
+	err := f.client.getParsed(ctx, f.config.APIURL+f.config.Board+"/thread/"+thread+".json", json.Unmarshal, &page)
+	if err != nil {
+		return nil, err
+	}
+	// Archived thread is closed for new posts
+	if len(page.Posts) != 0 && page.Posts[0].Archived != 0 {
+		return nil, ErrNotFound
+	}
+	for _, post := range page.Posts {
+		name := post.fileName()
+		if f.isMedia(name) {
+			files = append(files, FileInfo{
+				Name:      name,
+				Path:      f.config.Board + "/" + name,
+				Thread:    thread,
+				Post:      strconv.Itoa(post.No),
+				MD5:       post.Md5,
+				Size:      post.Fsize,
+				Width:     post.W,
+				Height:    post.H,
+				Fullname:  post.Filename + post.Ext,
+				Thumbnail: f.config.Board + "/" + strconv.FormatInt(post.Tim, 10) + "s.jpg",
+				Timestamp: post.Time,
+				Subject:   post.Sub,
+			})
+		}
+	}
+	return files, nil
+}
+
+// Function returns link to file on media host.
+func (f *FourChan) FileURL(file FileInfo) string {
+	return f.config.MediaURL + file.Path
+}
+
+// Function returns link to post in thread.
+func (f *FourChan) PostURL(file FileInfo) string {
+	return f.config.SiteURL + f.config.Board + "/thread/" + file.Thread + "#p" + file.Post
+}
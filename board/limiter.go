@@ -0,0 +1,141 @@
+package board
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Type represents counters of limiter
+type LimiterStats struct {
+	// Number of API requests sent and number of requests waiting for their turn now
+	APIRequests int64 `json:"apiRequests"`
+	APIWaiting  int   `json:"apiWaiting"`
+	// Total time API requests spent waiting
+	APIWaitTime time.Duration `json:"apiWaitTime"`
+	// Number of media downloads started, downloads in progress and downloads waiting for free slot
+	Downloads        int64 `json:"downloads"`
+	DownloadsActive  int   `json:"downloadsActive"`
+	DownloadsWaiting int   `json:"downloadsWaiting"`
+	// Total time downloads spent waiting
+	DownloadsWaitTime time.Duration `json:"downloadsWaitTime"`
+}
+
+// Type to limit requests to origin: API requests are spaced to fit requests per second limit, media
+// downloads are limited by number of concurrent ones. Requests over limits wait in queue.
+type limiter struct {
+	lock sync.Mutex
+
+	// Minimal interval between API requests and time of next free slot
+	interval time.Duration
+	next     time.Time
+
+	// Slots of concurrent downloads, nil means unlimited
+	downloads chan struct{}
+
+	stats LimiterStats
+}
+
+// Function to set limits. Zero rate or maxDownloads means no limit.
+func (l *limiter) setLimits(rate float64, maxDownloads int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.interval = 0
+	if rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / rate)
+	}
+	l.downloads = nil
+	if maxDownloads > 0 {
+		l.downloads = make(chan struct{}, maxDownloads)
+	}
+}
+
+// Function waits for turn of API request. Returns error if context is canceled while waiting.
+func (l *limiter) waitAPI(ctx context.Context) error {
+	l.lock.Lock()
+	now := time.Now()
+	slot := now
+	if l.next.After(slot) {
+		slot = l.next
+	}
+	l.next = slot.Add(l.interval)
+	l.stats.APIRequests++
+	l.stats.APIWaiting++
+	l.stats.APIWaitTime += slot.Sub(now)
+	l.lock.Unlock()
+
+	defer func() {
+		l.lock.Lock()
+		l.stats.APIWaiting--
+		l.lock.Unlock()
+	}()
+	if !slot.After(now) {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(slot.Sub(now)):
+		return nil
+	}
+}
+
+// Function waits for free download slot. Returned function should be called to free slot.
+func (l *limiter) acquireDownload(ctx context.Context) (func(), error) {
+	l.lock.Lock()
+	downloads := l.downloads
+	l.stats.DownloadsWaiting++
+	l.lock.Unlock()
+
+	start := time.Now()
+	var err error
+	if downloads != nil {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case downloads <- struct{}{}:
+		}
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.stats.DownloadsWaiting--
+	l.stats.DownloadsWaitTime += time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	l.stats.Downloads++
+	l.stats.DownloadsActive++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.lock.Lock()
+			l.stats.DownloadsActive--
+			l.lock.Unlock()
+			if downloads != nil {
+				<-downloads
+			}
+		})
+	}, nil
+}
+
+// Function returns copy of limiter counters.
+func (l *limiter) counters() LimiterStats {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.stats
+}
+
+// Type to free download slot when response body is closed
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Function closes body and frees download slot.
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,378 @@
+package board
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Type to parse file of post in thread. Size is in kilobytes.
+type boardFile struct {
+	Path          string
+	Name          string
+	Fullname      string
+	Thumbnail     string
+	Md5           string
+	Size          int64
+	Width         int
+	Height        int
+	Duration_secs int
+}
+
+// Function returns file info for file of indicated post.
+func (f boardFile) fileInfo(thread string, num int, subject string, timestamp int64) FileInfo {
+	return FileInfo{
+		Name:      f.Name,
+		Path:      f.Path,
+		Thread:    thread,
+		Post:      strconv.Itoa(num),
+		MD5:       f.Md5,
+		Size:      f.Size * 1024,
+		Width:     f.Width,
+		Height:    f.Height,
+		Duration:  f.Duration_secs,
+		Fullname:  f.Fullname,
+		Thumbnail: f.Thumbnail,
+		Timestamp: timestamp,
+		Subject:   subject,
+	}
+}
+
+// Type to parse JSON-view of imageboard page
+type boardPage struct {
+	Threads []struct {
+		Thread_num string
+		Posts      []struct {
+			Comment   string
+			Subject   string
+			Files     []boardFile
+			Num       int
+			Timestamp int64
+		}
+	}
+}
+
+// Type to parse JSON-view of Main imageboard page. There is Number os posts in string
+// instead of interer as on other pages. That's really not what i expected
+type boardMainPage struct {
+	Threads []struct {
+		Thread_num string
+		Posts      []struct {
+			Comment string
+			Subject string
+			Files   []struct {
+				Path string
+				Name string
+			}
+			Num string
+		}
+	}
+}
+
+// Type to parse JSON-view of board catalog with OP posts of all live threads. Number of thread could be
+// a string or a number.
+type boardCatalog struct {
+	Threads []struct {
+		Num     json.Number
+		Comment string
+		Subject string
+		Files   []struct {
+			Path string
+			Name string
+		}
+	}
+}
+
+// Type to parse JSON-view of posts after indicated one, returned by mobile API.
+type boardPostsAfter struct {
+	Posts []struct {
+		Subject   string
+		Files     []boardFile
+		Num       int
+		Timestamp int64
+	}
+	Error *struct {
+		Code    int
+		Message string
+	}
+}
+
+// Type to represent 2ch.hk(Makaba engine) as a source of WEBM files.
+type Makaba struct {
+	threadFilter
+	mediaFilter
+	client *Client
+
+	// Struct to save the configuration
+	config struct {
+		JSONUrl      string
+		DownloadURL  string
+		BoardAddress string
+
+		// Board name, used in mobile API
+		Board string
+
+		// Number of index pages to scan, catalog is used if zero
+		Pages int
+	}
+
+	// Number of last seen post of every thread, to fetch only new posts
+	lastPosts struct {
+		sync.Mutex
+		threads map[string]int
+	}
+
+	// Cookies are requested from board once, before first scan
+	cookies sync.Once
+}
+
+// Generates new Makaba source. If BoardAddress is empty, it will be taken from DownloadURL.
+func NewMakaba(client *Client, JSONUrl, DownloadURL, BoardAddress string) *Makaba {
+	source := new(Makaba)
+	source.client = client
+	source.config.JSONUrl = JSONUrl
+	source.config.DownloadURL = DownloadURL
+	source.config.BoardAddress = BoardAddress
+	source.lastPosts.threads = make(map[string]int)
+
+	address, err := url.Parse(DownloadURL)
+	if err != nil {
+		log.Println("Error on parsing download URL: ", err)
+	} else {
+		source.config.Board = strings.Trim(address.Path, "/")
+		if source.config.BoardAddress == "" {
+			source.config.BoardAddress = address.Scheme + "://" + address.Host + "/"
+		}
+	}
+
+	source.SetRules(DefaultRules())
+	source.SetExtensions(DefaultExtensions())
+
+	return source
+}
+
+// Function to set number of index pages to scan for a new threads. If pages is zero, catalog.json
+// with all live threads is used instead of index pages.
+func (m *Makaba) SetPages(pages int) {
+	m.config.Pages = pages
+}
+
+// Function to check if OP post starts WEBM thread.
+func (m *Makaba) isWebmThread(num, subject, comment string, paths []string) bool {
+	if m.isAllowed(num) {
+		return true
+	}
+	if !m.matchThread(num, subject, comment) {
+		return false
+	}
+	for _, path := range paths {
+		if m.isMedia(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to request cookies from board if we didn't recieve them in configuration. Request is done
+// only once and limited by DefaultRequestTimeout.
+func (m *Makaba) requestCookies(ctx context.Context) {
+	m.cookies.Do(func() {
+		if m.client.hasCookies(m.config.DownloadURL) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(ctx, DefaultRequestTimeout)
+		defer cancel()
+		err := m.client.getCFCookie(ctx, m.config.DownloadURL)
+		if err != nil {
+			log.Println("Error cookie request: ", err)
+		}
+	})
+}
+
+// Function to get WEBM threads from catalog or from index pages.
+func (m *Makaba) Threads(ctx context.Context) ([]string, error) {
+	var threads []string
+	var err error
+	m.requestCookies(ctx)
+	if m.config.Pages <= 0 {
+		threads, err = m.catalogThreads(ctx)
+	} else {
+		threads, err = m.pagesThreads(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.withAllowed(threads), nil
+}
+
+// Function to get WEBM threads from catalog of board.
+func (m *Makaba) catalogThreads(ctx context.Context) ([]string, error) {
+	var catalog boardCatalog
+	var threads []string
+	log.Println("Inititated scan of catalog for a new WEBM threads")
+	err := m.client.getParsed(ctx, m.config.DownloadURL+"catalog.json", json.Unmarshal, &catalog)
+	if err != nil {
+		return nil, err
+	}
+	for _, thread := range catalog.Threads {
+		var paths []string
+		for _, file := range thread.Files {
+			paths = append(paths, file.Path)
+		}
+		if m.isWebmThread(thread.Num.String(), thread.Subject, thread.Comment, paths) {
+			threads = append(threads, thread.Num.String())
+		}
+	}
+
+	return threads, nil
+}
+
+// Function to get WEBM threads from index pages of board. 0 page has it's own format.
+func (m *Makaba) pagesThreads(ctx context.Context) ([]string, error) {
+	var mainPage boardMainPage
+	var threads []string
+	log.Println("Inititated scan 0 page for a new WEBM threads")
+	err := m.client.getParsed(ctx, m.config.JSONUrl, json.Unmarshal, &mainPage)
+	if err == ErrNotModified {
+		// Threads from this page are already known
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, thread := range mainPage.Threads {
+		if len(thread.Posts) == 0 {
+			continue
+		}
+		var paths []string
+		for _, file := range thread.Posts[0].Files {
+			paths = append(paths, file.Path)
+		}
+		if m.isWebmThread(thread.Thread_num, thread.Posts[0].Subject, thread.Posts[0].Comment, paths) {
+			threads = append(threads, thread.Thread_num)
+		}
+	}
+
+	for number := 1; number < m.config.Pages; number++ {
+		var page boardPage
+		log.Println("Inititated scan ", number, " page for a new WEBM threads")
+		err := m.client.getParsed(ctx, m.config.DownloadURL+strconv.Itoa(number)+".json", json.Unmarshal, &page)
+		if err == ErrNotModified {
+			// Threads from this page are already known
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, thread := range page.Threads {
+			if len(thread.Posts) == 0 {
+				continue
+			}
+			var paths []string
+			for _, file := range thread.Posts[0].Files {
+				paths = append(paths, file.Path)
+			}
+			if m.isWebmThread(thread.Thread_num, thread.Posts[0].Subject, thread.Posts[0].Comment, paths) {
+				threads = append(threads, thread.Thread_num)
+			}
+		}
+	}
+
+	return threads, nil
+}
+
+// Function to get video files from thread. If thread was already fetched, only files from new posts
+// are requested, whole thread is fetched if there is no known posts or request of new ones failed.
+func (m *Makaba) Files(ctx context.Context, thread string) ([]FileInfo, error) {
+	m.lastPosts.Lock()
+	lastPost, ok := m.lastPosts.threads[thread]
+	m.lastPosts.Unlock()
+	if ok && m.config.Board != "" {
+		files, err := m.filesAfter(ctx, thread, lastPost)
+		if err == nil || err == ErrNotModified || ctx.Err() != nil {
+			return files, err
+		}
+		log.Println("Error on getting new posts of thread ", thread, ", fetching whole thread: ", err)
+	}
+	return m.allFiles(ctx, thread)
+}
+
+// Function to remember number of last seen post of thread.
+func (m *Makaba) seen(thread string, lastPost int) {
+	m.lastPosts.Lock()
+	defer m.lastPosts.Unlock()
+	if lastPost > m.lastPosts.threads[thread] {
+		m.lastPosts.threads[thread] = lastPost
+	}
+}
+
+// Function to forget last seen post of thread which is not watched anymore.
+func (m *Makaba) Forget(thread string) {
+	m.lastPosts.Lock()
+	defer m.lastPosts.Unlock()
+	delete(m.lastPosts.threads, thread)
+}
+
+// Function to get video files from posts after lastPost using mobile API.
+func (m *Makaba) filesAfter(ctx context.Context, thread string, lastPost int) ([]FileInfo, error) {
+	var after boardPostsAfter
+	var files []FileInfo
+	afterURL := m.config.BoardAddress + "api/mobile/v2/after/" + m.config.Board + "/" + thread + "/" + strconv.Itoa(lastPost+1)
+	err := m.client.getParsed(ctx, afterURL, json.Unmarshal, &after)
+	if err != nil {
+		return nil, err
+	}
+	if after.Error != nil {
+		m.client.forgetValidator(afterURL)
+		return nil, errors.New("Mobile API error " + strconv.Itoa(after.Error.Code) + ": " + after.Error.Message)
+	}
+	for _, post := range after.Posts {
+		if post.Num <= lastPost {
+			continue
+		}
+		for _, file := range post.Files {
+			if m.isMedia(file.Name) {
+				files = append(files, file.fileInfo(thread, post.Num, post.Subject, post.Timestamp))
+			}
+		}
+		m.seen(thread, post.Num)
+	}
+	return files, nil
+}
+
+// Function to get all video files from thread.
+func (m *Makaba) allFiles(ctx context.Context, thread string) ([]FileInfo, error) {
+	var page boardPage
+	var files []FileInfo
+	err := m.client.getParsed(ctx, m.config.DownloadURL+"res/"+thread+".json", json.Unmarshal, &page)
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Threads) == 0 {
+		return nil, nil
+	}
+	for _, post := range page.Threads[0].Posts {
+		for _, file := range post.Files {
+			if m.isMedia(file.Name) {
+				files = append(files, file.fileInfo(thread, post.Num, post.Subject, post.Timestamp))
+			}
+		}
+		m.seen(thread, post.Num)
+	}
+	return files, nil
+}
+
+// Function returns link to file on board.
+func (m *Makaba) FileURL(file FileInfo) string {
+	return m.config.BoardAddress + file.Path
+}
+
+// Function returns link to post in thread.
+func (m *Makaba) PostURL(file FileInfo) string {
+	return m.config.DownloadURL + "res/" + file.Thread + ".html#" + file.Post
+}
@@ -0,0 +1,80 @@
+package board
+
+import (
+	"mime"
+	"path"
+	"strings"
+	"sync"
+)
+
+// MIME types of video containers, which could be not known by system mime database
+var videoTypes = map[string]string{
+	".webm": "video/webm",
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mkv":  "video/x-matroska",
+	".mov":  "video/quicktime",
+	".ogv":  "video/ogg",
+}
+
+// Function returns default extensions of files to add to queue.
+func DefaultExtensions() []string {
+	return []string{".webm", ".mp4"}
+}
+
+// Function returns MIME type of file by it's extension.
+func MediaType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if mediaType, ok := videoTypes[ext]; ok {
+		return mediaType
+	}
+	if mediaType := mime.TypeByExtension(ext); mediaType != "" {
+		return mediaType
+	}
+	return "application/octet-stream"
+}
+
+// Type to embed in sources to filter files by extension.
+type mediaFilter struct {
+	lock       sync.RWMutex
+	extensions map[string]bool
+}
+
+// Function to replace set of extensions of files to add to queue, e.g. ".webm", ".mp4".
+func (f *mediaFilter) SetExtensions(extensions []string) {
+	set := make(map[string]bool)
+	for _, ext := range extensions {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[ext] = true
+	}
+	f.lock.Lock()
+	f.extensions = set
+	f.lock.Unlock()
+}
+
+// Function to check if file has one of media extensions.
+func (f *mediaFilter) isMedia(name string) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.extensions[strings.ToLower(path.Ext(name))]
+}
+
+// Function to check if MIME type belongs to one of media extensions. Parameters of type, e.g. codecs, are
+// ignored.
+func (f *mediaFilter) isMediaType(mediaType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return false
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	for ext := range f.extensions {
+		if extType, _, err := mime.ParseMediaType(MediaType(ext)); err == nil && extType == mediaType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package board
+
+import (
+	"sync"
+	"time"
+)
+
+// Default maximum number of files in queue
+const DefaultQueueCapacity = 10000
+
+// Type to keep file in queue with time when it was added. Removed entry is kept in queue until it's
+// evicted, so positions of following files are not changed.
+type queueEntry struct {
+	file    FileInfo
+	added   time.Time
+	removed bool
+}
+
+// Type to represent bounded queue of files on top of ring buffer. Every file gets absolute position which
+// is never changed when oldest files are evicted or any file is removed, so positions saved by sessions
+// stay valid: position is in queue if it's between First and End and file on it is not removed.
+type Queue struct {
+	lock sync.RWMutex
+
+	// Ring buffer, head is index of oldest entry
+	entries []queueEntry
+	head    int
+	count   int
+
+	// Number of removed entries in ring buffer
+	removed int
+
+	// Absolute position of oldest entry
+	first int
+
+	// Limits of queue, zero means unlimited
+	capacity int
+	maxAge   time.Duration
+}
+
+// Generates new queue with indicated limits. Zero capacity or maxAge means no limit.
+func NewQueue(capacity int, maxAge time.Duration) *Queue {
+	return &Queue{capacity: capacity, maxAge: maxAge}
+}
+
+// Function to change limits of queue. Extra files are evicted immediately and returned.
+func (q *Queue) SetLimits(capacity int, maxAge time.Duration) []FileInfo {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.capacity = capacity
+	q.maxAge = maxAge
+	return q.evict()
+}
+
+// Function returns entry by index in ring buffer counted from head.
+func (q *Queue) at(index int) *queueEntry {
+	return &q.entries[(q.head+index)%len(q.entries)]
+}
+
+// Function to grow ring buffer, entries are moved to the beginning.
+func (q *Queue) grow() {
+	size := len(q.entries) * 2
+	if size == 0 {
+		size = 16
+	}
+	if q.capacity > 0 && size > q.capacity {
+		size = q.capacity
+	}
+	entries := make([]queueEntry, size)
+	for i := 0; i < q.count; i++ {
+		entries[i] = *q.at(i)
+	}
+	q.entries = entries
+	q.head = 0
+}
+
+// Function to remove oldest entry. Returns it's file and false if file was already removed.
+func (q *Queue) popOldest() (FileInfo, bool) {
+	entry := q.at(0)
+	file, removed := entry.file, entry.removed
+	*entry = queueEntry{}
+	q.head = (q.head + 1) % len(q.entries)
+	q.count--
+	q.first++
+	if removed {
+		q.removed--
+	}
+	return file, !removed
+}
+
+// Function to evict entries over capacity and older than maxAge. Removed entries take place in queue
+// until they are evicted, but they are not returned. Lock should be held.
+func (q *Queue) evict() []FileInfo {
+	var evicted []FileInfo
+	for q.count > 0 && ((q.capacity > 0 && q.count > q.capacity) || (q.maxAge > 0 && time.Since(q.at(0).added) > q.maxAge) || q.at(0).removed) {
+		if file, ok := q.popOldest(); ok {
+			evicted = append(evicted, file)
+		}
+	}
+	return evicted
+}
+
+// Function to add entry to the end of ring buffer. If queue is full, oldest entry is evicted and returned.
+// Lock should be held.
+func (q *Queue) append(entry queueEntry) []FileInfo {
+	var evicted []FileInfo
+	if q.count == len(q.entries) {
+		if q.capacity > 0 && q.count >= q.capacity {
+			if file, ok := q.popOldest(); ok {
+				evicted = append(evicted, file)
+			}
+		} else {
+			q.grow()
+		}
+	}
+	*q.at(q.count) = entry
+	q.count++
+	if entry.removed {
+		q.removed++
+	}
+	return evicted
+}
+
+// Function to add files to the end of queue. Returns files evicted to fit limits.
+func (q *Queue) Push(files ...FileInfo) []FileInfo {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var evicted []FileInfo
+	now := time.Now()
+	for _, file := range files {
+		evicted = append(evicted, q.append(queueEntry{file: file, added: now})...)
+	}
+	return append(evicted, q.evict()...)
+}
+
+// Function to evict files older than maxAge. Returns evicted files.
+func (q *Queue) Evict() []FileInfo {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.evict()
+}
+
+// Function returns file by absolute position and false if position is out of queue or file was removed.
+func (q *Queue) Get(position int) (FileInfo, bool) {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	if position < q.first || position >= q.first+q.count {
+		return FileInfo{}, false
+	}
+	entry := q.at(position - q.first)
+	if entry.removed {
+		return FileInfo{}, false
+	}
+	return entry.file, true
+}
+
+// Function returns position of the nearest file which is not removed, starting from indicated position and
+// moving by step(1 to newer files or -1 to older ones). Returns false if there is no such file.
+func (q *Queue) Seek(position, step int) (int, bool) {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	for ; position >= q.first && position < q.first+q.count; position += step {
+		if !q.at(position - q.first).removed {
+			return position, true
+		}
+	}
+	return position, false
+}
+
+// Function to remove file by absolute position. Positions of other files are not changed, removed
+// position is just skipped.
+func (q *Queue) Remove(position int) (FileInfo, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if position < q.first || position >= q.first+q.count {
+		return FileInfo{}, false
+	}
+	entry := q.at(position - q.first)
+	if entry.removed {
+		return FileInfo{}, false
+	}
+	file := entry.file
+	entry.file = FileInfo{}
+	entry.removed = true
+	q.removed++
+	// Removed entries at the beginning are not needed anymore
+	for q.count > 0 && q.at(0).removed {
+		q.popOldest()
+	}
+	return file, true
+}
+
+// Function returns absolute positions of oldest file and position after newest one.
+func (q *Queue) Bounds() (first, end int) {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.first, q.first + q.count
+}
+
+// Function returns number of files in queue, removed files are not counted.
+func (q *Queue) Len() int {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.count - q.removed
+}
+
+// Function returns copy of all files in queue from oldest to newest, removed files are skipped.
+func (q *Queue) Files() []FileInfo {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	files := make([]FileInfo, 0, q.count-q.removed)
+	for i := 0; i < q.count; i++ {
+		if entry := q.at(i); !entry.removed {
+			files = append(files, entry.file)
+		}
+	}
+	return files
+}
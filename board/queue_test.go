@@ -0,0 +1,199 @@
+package board
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Function returns files with names from first to last-1.
+func testFiles(first, last int) []FileInfo {
+	var files []FileInfo
+	for i := first; i < last; i++ {
+		files = append(files, FileInfo{Name: strconv.Itoa(i)})
+	}
+	return files
+}
+
+// Function to check that queue contains files with indicated names from oldest to newest.
+func checkQueueFiles(t *testing.T, q *Queue, names ...int) {
+	t.Helper()
+	files := q.Files()
+	if len(files) != len(names) || q.Len() != len(names) {
+		t.Fatalf("Expected %d files, got %d files and Len %d", len(names), len(files), q.Len())
+	}
+	for i, name := range names {
+		if files[i].Name != strconv.Itoa(name) {
+			t.Fatalf("Expected file %d at %d, got %s", name, i, files[i].Name)
+		}
+	}
+}
+
+func TestQueuePositions(t *testing.T) {
+	q := NewQueue(0, 0)
+	if first, end := q.Bounds(); first != 0 || end != 0 {
+		t.Errorf("Empty queue bounds should be 0, 0, got %d, %d", first, end)
+	}
+	if _, ok := q.Get(0); ok {
+		t.Error("Get from empty queue should fail")
+	}
+
+	q.Push(testFiles(0, 40)...)
+	first, end := q.Bounds()
+	if first != 0 || end != 40 {
+		t.Errorf("Expected bounds 0, 40, got %d, %d", first, end)
+	}
+	for position := first; position < end; position++ {
+		file, ok := q.Get(position)
+		if !ok || file.Name != strconv.Itoa(position) {
+			t.Errorf("Unexpected file %v, %v at position %d", file, ok, position)
+		}
+	}
+	if _, ok := q.Get(-1); ok {
+		t.Error("Get before first position should fail")
+	}
+	if _, ok := q.Get(end); ok {
+		t.Error("Get at end position should fail")
+	}
+}
+
+func TestQueueCapacity(t *testing.T) {
+	q := NewQueue(5, 0)
+	evicted := q.Push(testFiles(0, 3)...)
+	if len(evicted) != 0 {
+		t.Errorf("Nothing should be evicted, got %v", evicted)
+	}
+	// Ring buffer wraps around several times
+	for i := 3; i < 23; i++ {
+		evicted = q.Push(testFiles(i, i+1)...)
+		if i >= 5 && (len(evicted) != 1 || evicted[0].Name != strconv.Itoa(i-5)) {
+			t.Fatalf("Expected file %d evicted, got %v", i-5, evicted)
+		}
+	}
+	checkQueueFiles(t, q, 18, 19, 20, 21, 22)
+	if first, end := q.Bounds(); first != 18 || end != 23 {
+		t.Errorf("Expected bounds 18, 23, got %d, %d", first, end)
+	}
+	if file, ok := q.Get(20); !ok || file.Name != "20" {
+		t.Errorf("Unexpected file %v, %v at position 20", file, ok)
+	}
+
+	evicted = q.SetLimits(2, 0)
+	if len(evicted) != 3 || evicted[0].Name != "18" || evicted[2].Name != "20" {
+		t.Errorf("Expected files 18-20 evicted, got %v", evicted)
+	}
+	checkQueueFiles(t, q, 21, 22)
+	if first, end := q.Bounds(); first != 21 || end != 23 {
+		t.Errorf("Expected bounds 21, 23, got %d, %d", first, end)
+	}
+}
+
+func TestQueueMaxAge(t *testing.T) {
+	q := NewQueue(0, time.Hour)
+	now := time.Now()
+	q.restore(10, []savedEntry{
+		{File: FileInfo{Name: "10"}, Added: now.Add(-3 * time.Hour)},
+		{File: FileInfo{Name: "11"}, Added: now.Add(-2 * time.Hour)},
+		{File: FileInfo{Name: "12"}, Added: now},
+	})
+	checkQueueFiles(t, q, 12)
+	if first, end := q.Bounds(); first != 12 || end != 13 {
+		t.Errorf("Expected bounds 12, 13, got %d, %d", first, end)
+	}
+
+	q.Push(testFiles(13, 14)...)
+	q.lock.Lock()
+	q.at(0).added = now.Add(-2 * time.Hour)
+	q.lock.Unlock()
+	evicted := q.Evict()
+	if len(evicted) != 1 || evicted[0].Name != "12" {
+		t.Errorf("Expected file 12 evicted, got %v", evicted)
+	}
+	checkQueueFiles(t, q, 13)
+}
+
+func TestQueueRemove(t *testing.T) {
+	q := NewQueue(0, 0)
+	q.Push(testFiles(0, 5)...)
+
+	file, ok := q.Remove(2)
+	if !ok || file.Name != "2" {
+		t.Fatalf("Unexpected removed file %v, %v", file, ok)
+	}
+	if _, ok := q.Remove(2); ok {
+		t.Error("File should not be removed twice")
+	}
+	if _, ok := q.Remove(5); ok {
+		t.Error("Remove out of queue should fail")
+	}
+	checkQueueFiles(t, q, 0, 1, 3, 4)
+
+	// Positions of other files are not changed
+	if _, ok := q.Get(2); ok {
+		t.Error("Get of removed file should fail")
+	}
+	if file, ok := q.Get(3); !ok || file.Name != "3" {
+		t.Errorf("Unexpected file %v, %v at position 3", file, ok)
+	}
+	if first, end := q.Bounds(); first != 0 || end != 5 {
+		t.Errorf("Expected bounds 0, 5, got %d, %d", first, end)
+	}
+	if position, ok := q.Seek(2, 1); !ok || position != 3 {
+		t.Errorf("Expected seek forward to 3, got %d, %v", position, ok)
+	}
+	if position, ok := q.Seek(2, -1); !ok || position != 1 {
+		t.Errorf("Expected seek backward to 1, got %d, %v", position, ok)
+	}
+
+	// Removed files at the beginning are dropped
+	q.Remove(0)
+	q.Remove(1)
+	if first, end := q.Bounds(); first != 3 || end != 5 {
+		t.Errorf("Expected bounds 3, 5, got %d, %d", first, end)
+	}
+	checkQueueFiles(t, q, 3, 4)
+
+	q.Remove(4)
+	if _, ok := q.Seek(4, 1); ok {
+		t.Error("Seek forward from last removed file should fail")
+	}
+	q.Push(testFiles(5, 6)...)
+	if file, ok := q.Get(5); !ok || file.Name != "5" {
+		t.Errorf("Unexpected file %v, %v at position 5", file, ok)
+	}
+	checkQueueFiles(t, q, 3, 5)
+}
+
+func TestQueueRemoveCapacity(t *testing.T) {
+	q := NewQueue(3, 0)
+	q.Push(testFiles(0, 3)...)
+	q.Remove(1)
+
+	// Removed file takes place until it's evicted, but it's not returned as evicted
+	evicted := q.Push(testFiles(3, 5)...)
+	if len(evicted) != 1 || evicted[0].Name != "0" {
+		t.Errorf("Expected only file 0 evicted, got %v", evicted)
+	}
+	checkQueueFiles(t, q, 2, 3, 4)
+	if first, end := q.Bounds(); first != 2 || end != 5 {
+		t.Errorf("Expected bounds 2, 5, got %d, %d", first, end)
+	}
+}
+
+func TestQueueSnapshot(t *testing.T) {
+	q := NewQueue(0, 0)
+	q.Push(testFiles(0, 4)...)
+	q.Remove(0)
+	q.Remove(2)
+
+	first, entries := q.snapshot()
+	restored := NewQueue(0, 0)
+	restored.restore(first, entries)
+	checkQueueFiles(t, restored, 1, 3)
+	if first, end := restored.Bounds(); first != 1 || end != 4 {
+		t.Errorf("Expected bounds 1, 4, got %d, %d", first, end)
+	}
+	if _, ok := restored.Get(2); ok {
+		t.Error("Removed file should stay removed after restore")
+	}
+}
@@ -0,0 +1,90 @@
+package board
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Default number of threads fetched in parallel and timeout of single thread request
+const (
+	DefaultRefreshWorkers = 4
+	DefaultRequestTimeout = 30 * time.Second
+)
+
+// Type to keep settings of parallel thread refresh
+type refreshConfig struct {
+	sync.Mutex
+	workers int
+	timeout time.Duration
+}
+
+// Type represents result of thread fetch
+type threadResult struct {
+	thread string
+	files  []FileInfo
+	err    error
+	// False if thread was not requested because refresh was aborted
+	done bool
+}
+
+// Function to set number of threads fetched in parallel and timeout of every thread request and scan of
+// board for a new threads. Zero timeout means no timeout.
+func (b *Board) SetRefreshWorkers(workers int, timeout time.Duration) error {
+	if workers < 1 {
+		return errors.New("Number of refresh workers should be positive")
+	}
+	b.refresh.Lock()
+	defer b.refresh.Unlock()
+	b.refresh.workers = workers
+	b.refresh.timeout = timeout
+	return nil
+}
+
+// Function to fetch files of single thread with timeout.
+func (b *Board) fetchThread(ctx context.Context, thread string, timeout time.Duration) ([]FileInfo, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return b.source.Files(ctx, thread)
+}
+
+// Function to fetch files of threads by pool of workers. Results are in the same order as threads.
+// Fetching is aborted if context is canceled.
+func (b *Board) fetchThreads(ctx context.Context, threads []string) []threadResult {
+	b.refresh.Lock()
+	workers, timeout := b.refresh.workers, b.refresh.timeout
+	b.refresh.Unlock()
+	if workers > len(threads) {
+		workers = len(threads)
+	}
+
+	results := make([]threadResult, len(threads))
+	jobs := make(chan int)
+	var wait sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for job := range jobs {
+				files, err := b.fetchThread(ctx, threads[job], timeout)
+				results[job] = threadResult{threads[job], files, err, true}
+			}
+		}()
+	}
+
+feed:
+	for job := range threads {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- job:
+		}
+	}
+	close(jobs)
+	wait.Wait()
+	return results
+}
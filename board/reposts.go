@@ -0,0 +1,99 @@
+package board
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Default time during which file with the same hash is treated as repost
+const DefaultRepostWindow = 24 * time.Hour
+
+// Type to describe queued file hash
+type repost struct {
+	// Time when file was added to queue
+	queued time.Time
+	// Number of reposts skipped since then
+	count int
+}
+
+// Type to detect reposts of the same file in different threads by hash. Files without hash are never
+// treated as reposts.
+type reposts struct {
+	sync.Mutex
+	window time.Duration
+	hashes map[string]*repost
+}
+
+// Function to set repost window, zero disables detection.
+func (r *reposts) setWindow(window time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	r.window = window
+	if window == 0 {
+		r.hashes = nil
+	}
+}
+
+// Function to forget hashes queued before window. Lock should be held.
+func (r *reposts) prune(now time.Time) {
+	for hash, queued := range r.hashes {
+		if now.Sub(queued.queued) > r.window {
+			delete(r.hashes, hash)
+		}
+	}
+}
+
+// Function to remember hash of file queued at indicated time, e.g. restored from state file.
+func (r *reposts) seen(hash string, queued time.Time) {
+	r.Lock()
+	defer r.Unlock()
+	if r.window == 0 || hash == "" || time.Since(queued) > r.window {
+		return
+	}
+	if r.hashes == nil {
+		r.hashes = make(map[string]*repost)
+	}
+	if old, ok := r.hashes[hash]; !ok || old.queued.Before(queued) {
+		r.hashes[hash] = &repost{queued: queued}
+	}
+}
+
+// Function returns files which were not queued within window and counts reposts of others.
+func (r *reposts) filter(files []FileInfo, now time.Time) []FileInfo {
+	r.Lock()
+	defer r.Unlock()
+	if r.window == 0 {
+		return files
+	}
+	if r.hashes == nil {
+		r.hashes = make(map[string]*repost)
+	}
+	r.prune(now)
+
+	var unique []FileInfo
+	for _, file := range files {
+		if file.MD5 == "" {
+			unique = append(unique, file)
+			continue
+		}
+		if queued, ok := r.hashes[file.MD5]; ok {
+			queued.count++
+			log.Println("Skipping repost ", file.Name, " from thread ", file.Thread, ", reposted ", queued.count, " times")
+			continue
+		}
+		r.hashes[file.MD5] = &repost{queued: now}
+		unique = append(unique, file)
+	}
+	return unique
+}
+
+// Function returns number of reposts of file with hash skipped within window.
+func (r *reposts) count(hash string) int {
+	r.Lock()
+	defer r.Unlock()
+	if queued, ok := r.hashes[hash]; ok {
+		return queued.count
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+package board
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepostsFilter(t *testing.T) {
+	var r reposts
+	r.setWindow(time.Hour)
+	now := time.Now()
+
+	files := []FileInfo{{Name: "1", MD5: "a"}, {Name: "2", MD5: "b"}, {Name: "3", MD5: "a"}, {Name: "4"}, {Name: "5"}}
+	checkNames(t, r.filter(files, now), "1", "2", "4", "5")
+	if count := r.count("a"); count != 1 {
+		t.Errorf("Expected 1 repost, got %d", count)
+	}
+
+	// Reposts are skipped within window
+	checkNames(t, r.filter([]FileInfo{{Name: "6", MD5: "b"}}, now.Add(30*time.Minute)))
+	if count := r.count("b"); count != 1 {
+		t.Errorf("Expected 1 repost, got %d", count)
+	}
+
+	// Window is counted from the time file was queued, not from the last repost
+	checkNames(t, r.filter([]FileInfo{{Name: "7", MD5: "b"}}, now.Add(61*time.Minute)), "7")
+	if count := r.count("b"); count != 0 {
+		t.Errorf("Reposts should be counted from zero after window, got %d", count)
+	}
+
+	// Hashes of restored files are remembered
+	r.seen("c", time.Now().Add(-30*time.Minute))
+	r.seen("d", time.Now().Add(-2*time.Hour))
+	checkNames(t, r.filter([]FileInfo{{Name: "8", MD5: "c"}, {Name: "9", MD5: "d"}}, time.Now()), "9")
+
+	// Zero window disables detection
+	r.setWindow(0)
+	checkNames(t, r.filter([]FileInfo{{Name: "10", MD5: "d"}, {Name: "11", MD5: "d"}}, time.Now()), "10", "11")
+}
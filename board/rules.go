@@ -0,0 +1,116 @@
+package board
+
+import (
+	"regexp"
+	"sync"
+)
+
+// Type to describe which threads should be watched. Include and Exclude are regexps which are checked
+// against thread number, subject and OP comment: thread is watched if any Include regexp matches(or
+// Include is empty) and none of Exclude regexps. Threads with numbers from Allow are watched always, so
+// Allow should be set only in rules of single board.
+type Rules struct {
+	Include []string
+	Exclude []string
+	Allow   []string
+}
+
+// Function returns default rules: threads with "WEBM" or "ЦУЙЬ" in OP post.
+func DefaultRules() Rules {
+	return Rules{Include: []string{webmThreadPattern}}
+}
+
+// Type to keep compiled rules
+type threadRules struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	allow   []string
+}
+
+// Function to compile all regexps of rules.
+func compileRules(rules Rules) (*threadRules, error) {
+	compiled := &threadRules{allow: rules.Allow}
+	for _, pattern := range rules.Include {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled.include = append(compiled.include, re)
+	}
+	for _, pattern := range rules.Exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled.exclude = append(compiled.exclude, re)
+	}
+	return compiled, nil
+}
+
+// Function to check if any of regexps matches any of fields.
+func matchAny(regexps []*regexp.Regexp, fields ...string) bool {
+	for _, re := range regexps {
+		for _, field := range fields {
+			if re.MatchString(field) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Type to embed in imageboard sources to filter threads by rules.
+type threadFilter struct {
+	lock  sync.RWMutex
+	rules *threadRules
+}
+
+// Function to replace rules of thread matching. Rules are not changed if any regexp is invalid.
+func (f *threadFilter) SetRules(rules Rules) error {
+	compiled, err := compileRules(rules)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	f.rules = compiled
+	f.lock.Unlock()
+	return nil
+}
+
+// Function to check if thread is in allow-list.
+func (f *threadFilter) isAllowed(num string) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	for _, allowed := range f.rules.allow {
+		if allowed == num {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to check if thread should be watched by it's number, subject and OP comment.
+func (f *threadFilter) matchThread(num, subject, comment string) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if matchAny(f.rules.exclude, num, subject, comment) {
+		return false
+	}
+	return len(f.rules.include) == 0 || matchAny(f.rules.include, num, subject, comment)
+}
+
+// Function to add threads from allow-list to list of found threads, if they are not there yet.
+func (f *threadFilter) withAllowed(threads []string) []string {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	found := make(map[string]bool)
+	for _, thread := range threads {
+		found[thread] = true
+	}
+	for _, allowed := range f.rules.allow {
+		if !found[allowed] {
+			threads = append(threads, allowed)
+		}
+	}
+	return threads
+}
@@ -0,0 +1,125 @@
+package board
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Default limits of thread polling interval
+const (
+	DefaultPollMinInterval = 1 * time.Minute
+	DefaultPollMaxInterval = 15 * time.Minute
+)
+
+// Type to keep polling schedule of single thread
+type threadPoll struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// Type to plan thread polls: threads with new files are polled more often, quiet threads less often.
+type scheduler struct {
+	sync.Mutex
+	min     time.Duration
+	max     time.Duration
+	threads map[string]*threadPoll
+}
+
+// Function to set limits of polling interval.
+func (s *scheduler) setIntervals(min, max time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	if max < min {
+		max = min
+	}
+	s.min = min
+	s.max = max
+	for _, poll := range s.threads {
+		poll.interval = s.limit(poll.interval)
+	}
+}
+
+// Function returns interval inside of limits. Lock should be held.
+func (s *scheduler) limit(interval time.Duration) time.Duration {
+	if interval < s.min {
+		return s.min
+	}
+	if interval > s.max {
+		return s.max
+	}
+	return interval
+}
+
+// Function returns threads which should be polled now. Threads without schedule, e.g. new or restored
+// from state file, are polled immediately.
+func (s *scheduler) due(threads []string, now time.Time) []string {
+	s.Lock()
+	defer s.Unlock()
+	var due []string
+	for _, thread := range threads {
+		poll, ok := s.threads[thread]
+		if !ok || !poll.next.After(now) {
+			due = append(due, thread)
+		}
+	}
+	return due
+}
+
+// Function returns time of the nearest poll of indicated threads.
+func (s *scheduler) nextPoll(threads []string, now time.Time) time.Time {
+	s.Lock()
+	defer s.Unlock()
+	next := now.Add(s.max)
+	for _, thread := range threads {
+		poll, ok := s.threads[thread]
+		if !ok {
+			return now
+		}
+		if poll.next.Before(next) {
+			next = poll.next
+		}
+	}
+	return next
+}
+
+// Function to plan next poll of thread after current one found newFiles files. Interval is halved for
+// threads with new files and grows by half for quiet ones. Next poll is shifted by random jitter up to 10%.
+func (s *scheduler) polled(thread string, newFiles int, now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	if s.threads == nil {
+		s.threads = make(map[string]*threadPoll)
+	}
+	poll, ok := s.threads[thread]
+	if !ok {
+		poll = &threadPoll{interval: s.min}
+		s.threads[thread] = poll
+	} else if newFiles > 0 {
+		poll.interval = s.limit(poll.interval / 2)
+	} else {
+		poll.interval = s.limit(poll.interval * 3 / 2)
+	}
+	poll.next = now.Add(poll.interval + time.Duration(rand.Int63n(int64(poll.interval/10)+1)))
+}
+
+// Function to plan retry of failed poll after minimal interval, interval of thread is not changed.
+func (s *scheduler) retry(thread string, now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	if poll, ok := s.threads[thread]; ok {
+		poll.next = now.Add(s.min)
+		return
+	}
+	if s.threads == nil {
+		s.threads = make(map[string]*threadPoll)
+	}
+	s.threads[thread] = &threadPoll{interval: s.min, next: now.Add(s.min)}
+}
+
+// Function to forget schedule of thread.
+func (s *scheduler) forget(thread string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.threads, thread)
+}
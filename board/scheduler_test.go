@@ -0,0 +1,78 @@
+package board
+
+import (
+	"testing"
+	"time"
+)
+
+// Function to check that next poll of thread is planned after interval with jitter up to 10%.
+func checkNextPoll(t *testing.T, s *scheduler, thread string, now time.Time, interval time.Duration) {
+	t.Helper()
+	poll := s.threads[thread]
+	if poll.interval != interval {
+		t.Fatalf("Expected interval %v, got %v", interval, poll.interval)
+	}
+	if poll.next.Before(now.Add(interval)) || poll.next.After(now.Add(interval+interval/10)) {
+		t.Fatalf("Next poll %v is not in %v + 10%%", poll.next.Sub(now), interval)
+	}
+}
+
+func TestSchedulerPolled(t *testing.T) {
+	var s scheduler
+	s.setIntervals(time.Minute, 10*time.Minute)
+	now := time.Now()
+
+	s.polled("1", 0, now)
+	checkNextPoll(t, &s, "1", now, time.Minute)
+
+	// Quiet thread is polled less often up to max
+	expected := time.Minute
+	for i := 0; i < 10; i++ {
+		s.polled("1", 0, now)
+		expected = expected * 3 / 2
+		if expected > 10*time.Minute {
+			expected = 10 * time.Minute
+		}
+		checkNextPoll(t, &s, "1", now, expected)
+	}
+
+	// Thread with new files is polled more often down to min
+	s.polled("1", 5, now)
+	checkNextPoll(t, &s, "1", now, 5*time.Minute)
+	for i := 0; i < 5; i++ {
+		s.polled("1", 1, now)
+	}
+	checkNextPoll(t, &s, "1", now, time.Minute)
+
+	// Intervals are limited by new limits
+	s.setIntervals(2*time.Minute, 3*time.Minute)
+	if s.threads["1"].interval != 2*time.Minute {
+		t.Errorf("Interval should be limited by new min, got %v", s.threads["1"].interval)
+	}
+}
+
+func TestSchedulerDue(t *testing.T) {
+	var s scheduler
+	s.setIntervals(time.Minute, 10*time.Minute)
+	now := time.Now()
+	s.polled("1", 0, now)
+	s.retry("2", now)
+
+	if due := s.due([]string{"1", "2", "3"}, now); len(due) != 1 || due[0] != "3" {
+		t.Errorf("Only thread without schedule should be due, got %v", due)
+	}
+	if next := s.nextPoll([]string{"1", "2", "3"}, now); !next.Equal(now) {
+		t.Errorf("Thread without schedule should be polled now, got %v", next.Sub(now))
+	}
+	if next := s.nextPoll([]string{"2"}, now); !next.Equal(now.Add(time.Minute)) {
+		t.Errorf("Failed poll should be retried after min interval, got %v", next.Sub(now))
+	}
+	if due := s.due([]string{"1", "2"}, now.Add(2*time.Minute)); len(due) != 2 {
+		t.Errorf("Both threads should be due, got %v", due)
+	}
+
+	s.forget("1")
+	if due := s.due([]string{"1"}, now); len(due) != 1 {
+		t.Errorf("Forgotten thread should be due, got %v", due)
+	}
+}
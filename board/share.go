@@ -0,0 +1,160 @@
+package board
+
+import (
+	"sync"
+	"time"
+)
+
+// Time during which new files of weighted board wait for new files of other boards to be mixed with them
+const shareWindow = 2 * time.Minute
+
+// Interface of source which has several boards with different weights, e.g. Aggregate.
+type weightedSource interface {
+	Boards() []string
+	Weight(board string) int
+}
+
+// Type to keep new file of weighted board until it's released to queue
+type pendingFile struct {
+	file  FileInfo
+	added time.Time
+}
+
+// Type to keep new files of weighted boards by board. Files are released to queue by weighted round-robin:
+// every board puts number of files equal to it's weight in a row, so files from small boards are not
+// buried under big ones.
+type pendingFiles struct {
+	sync.Mutex
+	boards map[string][]pendingFile
+}
+
+// Function to add new files to pending ones of their boards. Files of unknown boards are returned to be
+// queued right away.
+func (b *Board) addPending(source weightedSource, files []FileInfo, now time.Time) []FileInfo {
+	known := make(map[string]bool)
+	for _, board := range source.Boards() {
+		known[board] = true
+	}
+	b.pending.Lock()
+	defer b.pending.Unlock()
+	if b.pending.boards == nil {
+		b.pending.boards = make(map[string][]pendingFile)
+	}
+	var unknown []FileInfo
+	for _, file := range files {
+		if !known[file.Board] {
+			unknown = append(unknown, file)
+			continue
+		}
+		b.pending.boards[file.Board] = append(b.pending.boards[file.Board], pendingFile{file, now})
+	}
+	return unknown
+}
+
+// Function to release pending files by rounds of weighted round-robin. Round is released when every board
+// has pending files, or when files of some board waited longer than shareWindow, then boards without
+// pending files are skipped.
+func (b *Board) releasePending(source weightedSource, now time.Time) []FileInfo {
+	boards := source.Boards()
+	b.pending.Lock()
+	defer b.pending.Unlock()
+	var released []FileInfo
+	for {
+		full, expired := true, false
+		for _, board := range boards {
+			pending := b.pending.boards[board]
+			if len(pending) == 0 {
+				full = false
+			} else if now.Sub(pending[0].added) >= shareWindow {
+				expired = true
+			}
+		}
+		if !full && !expired {
+			return released
+		}
+
+		count := len(released)
+		for _, board := range boards {
+			pending := b.pending.boards[board]
+			weight := source.Weight(board)
+			if weight > len(pending) {
+				weight = len(pending)
+			}
+			for _, file := range pending[:weight] {
+				released = append(released, file.file)
+			}
+			b.pending.boards[board] = pending[weight:]
+		}
+		if count == len(released) {
+			return released
+		}
+	}
+}
+
+// Function returns time when the oldest pending file should be released, zero time if there are no
+// pending files.
+func (b *Board) nextRelease() time.Time {
+	b.pending.Lock()
+	defer b.pending.Unlock()
+	var next time.Time
+	for _, pending := range b.pending.boards {
+		if len(pending) != 0 && (next.IsZero() || pending[0].added.Before(next)) {
+			next = pending[0].added
+		}
+	}
+	if next.IsZero() {
+		return next
+	}
+	return next.Add(shareWindow)
+}
+
+// Function to release pending files to queue if they could be mixed or waited long enough.
+func (b *Board) release() {
+	if source, ok := b.source.(weightedSource); ok {
+		b.push(b.releasePending(source, time.Now()))
+	}
+}
+
+// Function returns copy of pending files from oldest to newest of every board.
+func (b *Board) pendingSnapshot() []savedEntry {
+	b.pending.Lock()
+	defer b.pending.Unlock()
+	var entries []savedEntry
+	for _, pending := range b.pending.boards {
+		for _, file := range pending {
+			entries = append(entries, savedEntry{File: file.file, Added: file.added})
+		}
+	}
+	return entries
+}
+
+// Function to restore pending files from snapshot. Files of boards which are not weighted anymore, e.g.
+// removed from configuration, are returned to be queued right away.
+func (b *Board) restorePending(entries []savedEntry) []FileInfo {
+	var files []FileInfo
+	var added []time.Time
+	for _, entry := range entries {
+		files = append(files, entry.File)
+		added = append(added, entry.Added)
+	}
+	source, ok := b.source.(weightedSource)
+	if !ok {
+		return files
+	}
+	known := make(map[string]bool)
+	for _, board := range source.Boards() {
+		known[board] = true
+	}
+	b.pending.Lock()
+	defer b.pending.Unlock()
+	b.pending.boards = make(map[string][]pendingFile)
+	var unknown []FileInfo
+	for i, file := range files {
+		if !known[file.Board] {
+			unknown = append(unknown, file)
+			continue
+		}
+		b.pending.boards[file.Board] = append(b.pending.boards[file.Board], pendingFile{file, added[i]})
+	}
+	return unknown
+}
@@ -0,0 +1,74 @@
+package board
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// Function returns files of board with indicated names.
+func boardFiles(board string, names ...string) []FileInfo {
+	var files []FileInfo
+	for _, name := range names {
+		files = append(files, FileInfo{Name: name, Board: board})
+	}
+	return files
+}
+
+// Function to check names of files from oldest to newest.
+func checkNames(t *testing.T, files []FileInfo, names ...string) {
+	t.Helper()
+	if len(files) != len(names) {
+		t.Fatalf("Expected files %v, got %v", names, files)
+	}
+	for i, name := range names {
+		if files[i].Name != name {
+			t.Fatalf("Expected files %v, got %v", names, files)
+		}
+	}
+}
+
+func TestWeightedShare(t *testing.T) {
+	root, err := ioutil.TempDir("", "share")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	aggregate := NewAggregate()
+	for _, board := range []struct {
+		name   string
+		weight int
+	}{{"a", 3}, {"b", 1}} {
+		source, err := NewDirectory(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		aggregate.Add(board.name, board.weight, source)
+	}
+	b, err := NewBoardWithSource(aggregate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files wait for files of other boards
+	b.enqueue(boardFiles("a", "a0", "a1", "a2", "a3", "a4"))
+	checkNames(t, b.Queue.Files())
+	b.enqueue(boardFiles("b", "b0", "b1"))
+	checkNames(t, b.Queue.Files(), "a0", "a1", "a2", "b0", "a3", "a4", "b1")
+
+	// Files of unknown boards are not delayed
+	b.enqueue(boardFiles("c", "c0"))
+	checkNames(t, b.Queue.Files(), "a0", "a1", "a2", "b0", "a3", "a4", "b1", "c0")
+
+	// Files are released without files of other boards after shareWindow
+	b.enqueue(boardFiles("a", "a5"))
+	if len(b.Queue.Files()) != 8 || b.nextRelease().IsZero() {
+		t.Fatal("File should wait for files of other boards")
+	}
+	source := b.source.(weightedSource)
+	checkNames(t, b.releasePending(source, time.Now().Add(shareWindow)), "a5")
+	if !b.nextRelease().IsZero() {
+		t.Error("There should be no pending files")
+	}
+}
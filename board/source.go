@@ -0,0 +1,38 @@
+package board
+
+import (
+	"context"
+	"net/http"
+)
+
+// Pattern to check if thread is a WEBM-thread by it's subject or OP comment
+const webmThreadPattern = "([ШшWw][EeЕе][BbБб].*[MmМм])|([Цц][Уу][ИЙйи].*[Ьь])"
+
+// Interface of origin which feeds Board with threads and files. Board itself only keeps cache and
+// queue, all origin-specific logic (URLs, JSON layout, thread matching) lives in implementations.
+type Source interface {
+	// Function returns numbers of threads which should be watched for a new files.
+	Threads(ctx context.Context) ([]string, error)
+
+	// Function returns suitable files from indicated thread. Source may return only files added since
+	// previous call, Board skips already known files anyway.
+	Files(ctx context.Context, thread string) ([]FileInfo, error)
+
+	// Function returns absolute URL to download file from origin.
+	FileURL(file FileInfo) string
+
+	// Function returns link to post with file for humans, or empty string if there is no such page.
+	PostURL(file FileInfo) string
+}
+
+// Interface of sources which serve their files without network, e.g. local files. Downloads of such
+// files never go through Client.
+type fileServer interface {
+	transport(file FileInfo) http.RoundTripper
+}
+
+// Interface of sources which keep per-thread state, e.g. last seen post. Board calls Forget when thread
+// is removed from cache.
+type forgetter interface {
+	Forget(thread string)
+}
@@ -0,0 +1,160 @@
+package board
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Type to save queue entry to state file
+type savedEntry struct {
+	File    FileInfo  `json:"file"`
+	Added   time.Time `json:"added"`
+	Removed bool      `json:"removed,omitempty"`
+}
+
+// Type represents snapshot of board state saved to file
+type boardState struct {
+	Threads    map[string]map[string]string `json:"threads"`
+	QueueFirst int                          `json:"queueFirst"`
+	Queue      []savedEntry                 `json:"queue"`
+	// New files of weighted boards which are not released to queue yet
+	Pending []savedEntry `json:"pending,omitempty"`
+}
+
+// Function returns snapshot of queue: position of oldest file and all entries, including removed ones to
+// keep positions.
+func (q *Queue) snapshot() (int, []savedEntry) {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	entries := make([]savedEntry, q.count)
+	for i := range entries {
+		entry := q.at(i)
+		entries[i] = savedEntry{entry.file, entry.added, entry.removed}
+	}
+	return q.first, entries
+}
+
+// Function to replace content of queue with snapshot. Returns files evicted to fit limits.
+func (q *Queue) restore(first int, entries []savedEntry) []FileInfo {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.entries = nil
+	q.head = 0
+	q.count = 0
+	q.removed = 0
+	q.first = first
+	var evicted []FileInfo
+	for _, entry := range entries {
+		evicted = append(evicted, q.append(queueEntry{entry.File, entry.Added, entry.Removed})...)
+	}
+	return append(evicted, q.evict()...)
+}
+
+// Function to load board state from file and remember file to save state later. Missing file is not an
+// error, board just starts with empty state.
+func (b *Board) LoadState(path string) error {
+	var state boardState
+	b.stateFile = path
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Println("There is no board state file ", path, ", starting with empty queue")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(content, &state)
+	if err != nil {
+		return err
+	}
+
+	b.cache.Lock()
+	if state.Threads != nil {
+		b.cache.threads = state.Threads
+	}
+	b.cache.Unlock()
+	for _, entry := range state.Queue {
+		b.reposts.seen(entry.File.MD5, entry.Added)
+	}
+	for _, entry := range state.Pending {
+		b.reposts.seen(entry.File.MD5, entry.Added)
+	}
+	evicted := b.Queue.restore(state.QueueFirst, state.Queue)
+	if len(evicted) != 0 {
+		log.Println("Evicted ", len(evicted), " old files from restored queue")
+	}
+	b.push(b.restorePending(state.Pending))
+	log.Println("Loaded board state from ", path, ": ", len(state.Threads), " threads, ", b.Queue.Len(), " files")
+	return nil
+}
+
+// Function to remember that threads cache or queue is changed and state should be saved.
+func (b *Board) stateChanged() {
+	b.changes.Lock()
+	b.changes.changed = true
+	b.changes.Unlock()
+}
+
+// Function to save board state only if it's changed since last save. Changes made during save are
+// saved next time.
+func (b *Board) saveChangedState() error {
+	b.changes.Lock()
+	changed := b.changes.changed
+	b.changes.changed = false
+	b.changes.Unlock()
+	if !changed {
+		return nil
+	}
+	err := b.SaveState()
+	if err != nil {
+		b.stateChanged()
+	}
+	return err
+}
+
+// Function to save board state to file indicated in LoadState. File is replaced atomically.
+func (b *Board) SaveState() error {
+	if b.stateFile == "" {
+		return nil
+	}
+	var state boardState
+
+	b.cache.RLock()
+	state.Threads = make(map[string]map[string]string, len(b.cache.threads))
+	for thread, files := range b.cache.threads {
+		state.Threads[thread] = make(map[string]string, len(files))
+		for name, path := range files {
+			state.Threads[thread][name] = path
+		}
+	}
+	b.cache.RUnlock()
+	state.QueueFirst, state.Queue = b.Queue.snapshot()
+	state.Pending = b.pendingSnapshot()
+
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(b.stateFile), filepath.Base(b.stateFile))
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	err = os.Rename(file.Name(), b.stateFile)
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
@@ -0,0 +1,218 @@
+package board
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+)
+
+// Type to parse vichan threads.json. There are only numbers of threads without OP posts.
+type vichanThreadsList []struct {
+	Page    int
+	Threads []struct {
+		No int `json:"no"`
+	}
+}
+
+// Type to parse vichan res/<id>.json
+type vichanThread struct {
+	Posts []vichanPost
+}
+
+// Type to describe file of vichan post. Tim could be a string or a number depending on engine version.
+type vichanFile struct {
+	Tim      json.Number `json:"tim"`
+	Ext      string      `json:"ext"`
+	Filename string      `json:"filename"`
+	Md5      string      `json:"md5"`
+	Fsize    int64       `json:"fsize"`
+	W        int         `json:"w"`
+	H        int         `json:"h"`
+}
+
+// Type to parse single post of vichan thread. First file is in post itself, others are in extra_files.
+type vichanPost struct {
+	vichanFile
+	No         int          `json:"no"`
+	Sub        string       `json:"sub"`
+	Com        string       `json:"com"`
+	Time       int64        `json:"time"`
+	ExtraFiles []vichanFile `json:"extra_files"`
+}
+
+// Function returns all files of post.
+func (p vichanPost) files() []vichanFile {
+	return append([]vichanFile{p.vichanFile}, p.ExtraFiles...)
+}
+
+// Function returns name of file in board src directory or empty string if there is no file.
+func (f vichanFile) fileName() string {
+	if f.Tim == "" || f.Ext == "" {
+		return ""
+	}
+	return f.Tim.String() + f.Ext
+}
+
+// Type to represent vichan/Tinyboard board as a source of WEBM files.
+type Vichan struct {
+	threadFilter
+	mediaFilter
+	client *Client
+
+	// Struct to save the configuration
+	config struct {
+		URL   string
+		Board string
+	}
+
+	// Threads already checked for WEBM: threads.json has no OP posts, so every new thread
+	// is checked once and result is saved here
+	checked struct {
+		sync.Mutex
+		threads map[string]bool
+	}
+}
+
+// Generates new vichan source. URL is a site root(e.g. https://example.org/), Board is a short name of board.
+func NewVichan(client *Client, URL, Board string) *Vichan {
+	source := new(Vichan)
+	source.client = client
+	source.config.URL = URL
+	source.config.Board = Board
+	source.checked.threads = make(map[string]bool)
+
+	source.SetRules(DefaultRules())
+	source.SetExtensions(DefaultExtensions())
+
+	return source
+}
+
+// Function returns URL of thread JSON.
+func (v *Vichan) threadURL(thread string) string {
+	return v.config.URL + v.config.Board + "/res/" + thread + ".json"
+}
+
+// Function to get thread JSON.
+func (v *Vichan) getThread(ctx context.Context, thread string) (*vichanThread, error) {
+	var page vichanThread
+	err := v.client.getParsed(ctx, v.threadURL(thread), json.Unmarshal, &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Function to check if thread is a WEBM thread by it's OP post.
+func (v *Vichan) isWebmThread(ctx context.Context, thread string) (bool, error) {
+	page, err := v.getThread(ctx, thread)
+	if err != nil {
+		return false, err
+	}
+	// Files of thread are not taken yet, so the next request should not be conditional
+	v.client.forgetValidator(v.threadURL(thread))
+	if len(page.Posts) == 0 {
+		return false, nil
+	}
+	op := page.Posts[0]
+	if !v.matchThread(thread, op.Sub, op.Com) {
+		return false, nil
+	}
+	for _, file := range op.files() {
+		if v.isMedia(file.fileName()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Function to get WEBM threads from all board pages.
+func (v *Vichan) Threads(ctx context.Context) ([]string, error) {
+	var list vichanThreadsList
+	var threads []string
+	log.Println("Inititated scan of /" + v.config.Board + "/ threads list for a new WEBM threads")
+	err := v.client.getParsed(ctx, v.config.URL+v.config.Board+"/threads.json", json.Unmarshal, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	alive := make(map[string]bool)
+	for _, page := range list {
+		for _, thread := range page.Threads {
+			num := strconv.Itoa(thread.No)
+			alive[num] = true
+			if v.isAllowed(num) {
+				threads = append(threads, num)
+				continue
+			}
+			v.checked.Lock()
+			webm, ok := v.checked.threads[num]
+			v.checked.Unlock()
+			if !ok {
+				webm, err = v.isWebmThread(ctx, num)
+				if err != nil {
+					log.Println("Error on checking thread ", num, ": ", err)
+					continue
+				}
+				v.checked.Lock()
+				v.checked.threads[num] = webm
+				v.checked.Unlock()
+			}
+			if webm {
+				threads = append(threads, num)
+			}
+		}
+	}
+
+	// Forget threads which gone from board
+	v.checked.Lock()
+	for num := range v.checked.threads {
+		if !alive[num] {
+			delete(v.checked.threads, num)
+		}
+	}
+	v.checked.Unlock()
+
+	return v.withAllowed(threads), nil
+}
+
+// Function to get all video files from thread.
+func (v *Vichan) Files(ctx context.Context, thread string) ([]FileInfo, error) {
+	var files []FileInfo
+	page, err := v.getThread(ctx, thread)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range page.Posts {
+		for _, file := range post.files() {
+			name := file.fileName()
+			if v.isMedia(name) {
+				files = append(files, FileInfo{
+					Name:      name,
+					Path:      v.config.Board + "/src/" + name,
+					Thread:    thread,
+					Post:      strconv.Itoa(post.No),
+					MD5:       file.Md5,
+					Size:      file.Fsize,
+					Width:     file.W,
+					Height:    file.H,
+					Fullname:  file.Filename + file.Ext,
+					Timestamp: post.Time,
+					Subject:   post.Sub,
+				})
+			}
+		}
+	}
+	return files, nil
+}
+
+// Function returns link to file in board src directory.
+func (v *Vichan) FileURL(file FileInfo) string {
+	return v.config.URL + file.Path
+}
+
+// Function returns link to post in thread.
+func (v *Vichan) PostURL(file FileInfo) string {
+	return v.config.URL + v.config.Board + "/res/" + file.Thread + ".html#" + file.Post
+}